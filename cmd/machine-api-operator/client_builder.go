@@ -39,11 +39,14 @@ func (cb *ClientBuilder) MachineClientOrDie(name string) mapiclientset.Interface
 }
 
 // NewClientBuilder returns a *ClientBuilder with the given kubeconfig.
-func NewClientBuilder(kubeconfig string) (*ClientBuilder, error) {
+// All the clients it builds are rate limited to the given QPS and burst.
+func NewClientBuilder(kubeconfig string, qps float32, burst int) (*ClientBuilder, error) {
 	config, err := getRestConfig(kubeconfig)
 	if err != nil {
 		return nil, err
 	}
+	config.QPS = qps
+	config.Burst = burst
 
 	return &ClientBuilder{
 		config: config,
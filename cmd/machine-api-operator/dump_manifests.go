@@ -0,0 +1,65 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/openshift/machine-api-operator/pkg/operator"
+	"github.com/spf13/cobra"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/yaml"
+)
+
+var (
+	dumpManifestsCmd = &cobra.Command{
+		Use:   "dump-manifests",
+		Short: "Writes the manifests Machine API Operator would apply to a directory",
+		Long: `Renders every object Machine API Operator would apply for the given operator config and
+writes each of them to <output-dir>/<kind>/<name>.yaml, e.g. for review or offline validation.
+With --output-dir - they are printed to stdout as a single YAML stream instead, e.g. to diff
+them against the expected output. No cluster connection is needed.`,
+		Run: runDumpManifestsCmd,
+	}
+
+	dumpManifestsOpts struct {
+		configFile              string
+		outputDir               string
+		controllersMinAvailable int
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(dumpManifestsCmd)
+	dumpManifestsCmd.PersistentFlags().StringVar(&dumpManifestsOpts.configFile, "operator-config", "", "YAML or JSON file holding the operator config to render the manifests for.")
+	dumpManifestsCmd.PersistentFlags().StringVar(&dumpManifestsOpts.outputDir, "output-dir", "manifests", "The directory to write the manifests to, or - to print them to stdout.")
+	dumpManifestsCmd.PersistentFlags().IntVar(&dumpManifestsOpts.controllersMinAvailable, "controllers-min-available", operator.DefaultControllersMinAvailable, "The minAvailable of the PodDisruptionBudget of the machine-api-controllers Deployment. Negative derives it from the replicas, allowing one controller pod to be disrupted at a time.")
+}
+
+func runDumpManifestsCmd(cmd *cobra.Command, args []string) {
+	if dumpManifestsOpts.configFile == "" {
+		klog.Fatalf("--operator-config is required")
+	}
+
+	data, err := ioutil.ReadFile(filepath.Clean(dumpManifestsOpts.configFile))
+	if err != nil {
+		klog.Fatalf("Error reading operator config: %v", err)
+	}
+	config := &operator.OperatorConfig{}
+	if err := yaml.Unmarshal(data, config); err != nil {
+		klog.Fatalf("Error parsing operator config: %v", err)
+	}
+	if config.TargetNamespace == "" {
+		config.TargetNamespace = componentNamespace
+	}
+
+	if dumpManifestsOpts.outputDir == "-" {
+		if err := operator.PrintManifests(config, dumpManifestsOpts.controllersMinAvailable, os.Stdout); err != nil {
+			klog.Fatalf("Error printing manifests: %v", err)
+		}
+		return
+	}
+	if err := operator.WriteManifests(config, dumpManifestsOpts.controllersMinAvailable, dumpManifestsOpts.outputDir); err != nil {
+		klog.Fatalf("Error writing manifests: %v", err)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"math/rand"
 	"os"
 	"time"
@@ -9,6 +10,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/uuid"
 	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/leaderelection"
 	"k8s.io/client-go/tools/leaderelection/resourcelock"
 	"k8s.io/client-go/tools/record"
 	"k8s.io/klog/v2"
@@ -32,6 +34,23 @@ func resyncPeriod() func() time.Duration {
 	}
 }
 
+// validateLeaderElectionDurations checks the leader election durations the way
+// leaderelection.RunOrDie does, which panics on invalid ones.
+func validateLeaderElectionDurations(leaseDuration, renewDeadline, retryPeriod time.Duration) error {
+	if retryPeriod <= 0 {
+		return fmt.Errorf("--leader-elect-retry-period must be positive, got %v", retryPeriod)
+	}
+	if renewDeadline <= time.Duration(leaderelection.JitterFactor*float64(retryPeriod)) {
+		return fmt.Errorf("--leader-elect-renew-deadline must be greater than %v times --leader-elect-retry-period, got %v and %v",
+			leaderelection.JitterFactor, renewDeadline, retryPeriod)
+	}
+	if leaseDuration <= renewDeadline {
+		return fmt.Errorf("--leader-elect-lease-duration must be greater than --leader-elect-renew-deadline, got %v and %v",
+			leaseDuration, renewDeadline)
+	}
+	return nil
+}
+
 // CreateResourceLock returns an interface for the resource lock.
 func CreateResourceLock(cb *ClientBuilder, componentNamespace, componentName string) resourcelock.Interface {
 	recorder := record.
@@ -24,6 +24,8 @@ var (
 )
 
 func init() {
+	rootCmd.Version = versionString()
+	rootCmd.SetVersionTemplate("{{.Version}}\n")
 	rootCmd.PersistentFlags().AddGoFlagSet(flag.CommandLine)
 }
 
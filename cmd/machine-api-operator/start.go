@@ -2,11 +2,14 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"net/http"
 	"os"
 	"strconv"
+	"sync"
+	"time"
 
 	osconfigv1 "github.com/openshift/api/config/v1"
 	"github.com/openshift/machine-api-operator/pkg/metrics"
@@ -20,15 +23,23 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes"
 	coreclientsetv1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/leaderelection"
 	"k8s.io/client-go/tools/record"
 	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/manager/signals"
 )
 
 const (
 	// defaultMetricsPort is the default port to expose metrics.
 	defaultMetricsPort = 8080
+
+	// defaultEventComponent is the default source component set on the events the operator records.
+	defaultEventComponent = "machineapioperator"
+
+	// defaultHealthAddr is the default address to serve the health and readiness endpoints on.
+	defaultHealthAddr = ":9440"
 )
 
 var (
@@ -40,18 +51,60 @@ var (
 	}
 
 	startOpts struct {
-		kubeconfig string
-		imagesFile string
+		kubeconfig   string
+		kubeAPIQPS   float32
+		kubeAPIBurst int
+		imagesFiles  []string
+		healthAddr   string
+
+		// operator holds the options the operator is started with.
+		operator operator.Options
+
+		eventComponent string
+
+		leaderElectResourceName  string
+		leaderElectLeaseDuration time.Duration
+		leaderElectRenewDeadline time.Duration
+		leaderElectRetryPeriod   time.Duration
 	}
 )
 
 func init() {
 	rootCmd.AddCommand(startCmd)
 	startCmd.PersistentFlags().StringVar(&startOpts.kubeconfig, "kubeconfig", "", "Kubeconfig file to access a remote cluster (testing only)")
-	startCmd.PersistentFlags().StringVar(&startOpts.imagesFile, "images-json", "", "images.json file for MAO.")
+	startCmd.PersistentFlags().Float32Var(&startOpts.kubeAPIQPS, "kube-api-qps", rest.DefaultQPS, "The maximum queries per second from the operator's clients to the API server.")
+	startCmd.PersistentFlags().IntVar(&startOpts.kubeAPIBurst, "kube-api-burst", rest.DefaultBurst, "The maximum burst of queries from the operator's clients to the API server.")
+	startCmd.PersistentFlags().StringSliceVar(&startOpts.imagesFiles, "images-json", nil, "images.json file for MAO. May be repeated, fields from later files override earlier ones and missing files after the first are skipped.")
+	startCmd.PersistentFlags().DurationVar(&startOpts.operator.DrainTimeout, "shutdown-drain-timeout", operator.DefaultDrainTimeout, "The maximum duration to wait for in-flight syncs to finish on shutdown.")
+	startCmd.PersistentFlags().StringVar(&startOpts.healthAddr, "health-addr", defaultHealthAddr, "The address to serve the /healthz and /readyz endpoints on, also while waiting for the leader lease. Empty disables them.")
+	startCmd.PersistentFlags().IntVar(&startOpts.operator.MaxRetries, "max-retries", operator.DefaultMaxRetries, "The number of times a failed sync is retried before it is dropped out of the queue.")
+	startCmd.PersistentFlags().DurationVar(&startOpts.operator.BaseBackoff, "retry-base-backoff", operator.DefaultBaseBackoff, "The delay before the first retry of a failed sync. It doubles on every further retry.")
+	startCmd.PersistentFlags().DurationVar(&startOpts.operator.MaxBackoff, "retry-max-backoff", operator.DefaultMaxBackoff, "The maximum delay between retries of a failed sync.")
+	startCmd.PersistentFlags().DurationVar(&startOpts.operator.ResyncInterval, "resync-interval", operator.DefaultResyncInterval, "The interval at which a full sync is run even without watch events. Zero disables it.")
+	startCmd.PersistentFlags().DurationVar(&startOpts.operator.SyncDebounce, "sync-debounce", operator.DefaultSyncDebounce, "The window in which syncs triggered by watch events are coalesced into a single sync.")
+	startCmd.PersistentFlags().DurationVar(&startOpts.operator.RolloutPollInterval, "rollout-poll-interval", operator.DefaultRolloutPollInterval, "The interval at which the rollout of the operands is checked.")
+	startCmd.PersistentFlags().DurationVar(&startOpts.operator.RolloutTimeout, "rollout-timeout", operator.DefaultRolloutTimeout, "The maximum duration to wait for an operand to roll out before the sync fails.")
+	startCmd.PersistentFlags().BoolVar(&startOpts.operator.RequireImageDigests, "require-image-digests", false, "Fail the sync if any operand image is referenced by tag rather than pinned by digest.")
+	startCmd.PersistentFlags().BoolVar(&startOpts.operator.DefaultMissingImages, "default-missing-images", false, "Fill the images missing from the images files with built-in, unpinned defaults. Otherwise an image required by the platform but missing from the files fails the sync.")
+	startCmd.PersistentFlags().Int32Var(&startOpts.operator.ControllerReplicas, "controller-replicas", operator.DefaultControllerReplicas, "The number of replicas of the machine-api-controllers Deployment. The controllers run with leader election so only one of them reconciles at a time.")
+	startCmd.PersistentFlags().Var(newJSONFlag(&startOpts.operator.ControllerTolerations, "tolerations"), "controller-tolerations", "A JSON list of tolerations added to the machine-api-controllers pods, which already tolerate the master taint.")
+	startCmd.PersistentFlags().Var(newJSONFlag(&startOpts.operator.ControllerTopologySpreadConstraints, "topologySpreadConstraints"), "controller-topology-spread-constraints", "A JSON list of topology spread constraints set on the machine-api-controllers pods, e.g. to spread their replicas across zones.")
+	startCmd.PersistentFlags().Var(newJSONFlag(&startOpts.operator.ControllerExtraVolumes, "volumes"), "controller-extra-volumes", "A JSON list of volumes added to the machine-api-controllers pods. Their names must not clash with the volumes of the pods.")
+	startCmd.PersistentFlags().Var(newJSONFlag(&startOpts.operator.ControllerExtraVolumeMounts, "volumeMounts"), "controller-extra-volume-mounts", "A JSON list of volume mounts added to the machine-controller container of the machine-api-controllers pods.")
+	startCmd.PersistentFlags().Var(newJSONFlag(&startOpts.operator.ControllerInitContainers, "containers"), "controller-init-containers", "A JSON list of init containers run in the machine-api-controllers pods before the controllers start. Their names must not clash with the containers of the pods.")
+	startCmd.PersistentFlags().StringVar((*string)(&startOpts.operator.ImagePullPolicy), "image-pull-policy", "", "The image pull policy of the machine-api-controllers containers: Always, IfNotPresent or Never. Empty leaves it to the API server default.")
+	startCmd.PersistentFlags().Var(newJSONFlag(&startOpts.operator.Resources, "resources"), "resources", "A JSON object of resource requirements replacing the defaults of the operand containers, keyed by container name: machineset-controller, machine-controller, nodelink-controller, machine-healthcheck-controller, kube-rbac-proxy or termination-handler.")
+	startCmd.PersistentFlags().StringSliceVar(&startOpts.operator.AllowedNamespaces, "allowed-namespaces", nil, "The namespaces the operator may write operands into. Defaults to the namespace of the operator.")
+	startCmd.PersistentFlags().IntVar(&startOpts.operator.ControllersMinAvailable, "controllers-min-available", operator.DefaultControllersMinAvailable, "The minAvailable of the PodDisruptionBudget of the machine-api-controllers Deployment. Negative derives it from the replicas, allowing one controller pod to be disrupted at a time.")
+	startCmd.PersistentFlags().BoolVar(&startOpts.operator.Standalone, "standalone", false, "Run without the cluster-version-operator: report status into the machine-api-operator-status configmap instead of the ClusterOperator.")
+	startCmd.PersistentFlags().BoolVar(&startOpts.operator.Observe, "observe", false, "Report the operands which drifted from the desired state in events and the Progressing condition instead of applying them.")
+	startCmd.PersistentFlags().StringVar(&startOpts.eventComponent, "event-component", defaultEventComponent, "The source component name set on the events recorded by the operator.")
+	startCmd.PersistentFlags().StringVar(&startOpts.leaderElectResourceName, "leader-elect-resource-name", componentName, "The name of the configmap used for locking during leader election.")
+	startCmd.PersistentFlags().DurationVar(&startOpts.leaderElectLeaseDuration, "leader-elect-lease-duration", LeaseDuration, "The duration that non-leader candidates will wait before attempting to acquire leadership of an unrenewed leader slot.")
+	startCmd.PersistentFlags().DurationVar(&startOpts.leaderElectRenewDeadline, "leader-elect-renew-deadline", RenewDeadline, "The duration that the acting leader will retry refreshing leadership before giving up.")
+	startCmd.PersistentFlags().DurationVar(&startOpts.leaderElectRetryPeriod, "leader-elect-retry-period", RetryPeriod, "The duration the clients should wait between attempting acquisition and renewal of leadership.")
 
 	klog.InitFlags(nil)
-	flag.Parse()
 	pflag.CommandLine.AddGoFlagSet(flag.CommandLine)
 }
 
@@ -59,41 +112,95 @@ func runStartCmd(cmd *cobra.Command, args []string) {
 	flag.Set("logtostderr", "true")
 
 	// To help debugging, immediately log version
-	klog.Infof("Version: %+v", version.Version)
+	info := version.Get()
+	klog.InfoS("Version", "version", info.Version, "commit", info.Commit, "buildDate", info.BuildDate)
 
-	if startOpts.imagesFile == "" {
+	if len(startOpts.imagesFiles) == 0 || startOpts.imagesFiles[0] == "" {
 		klog.Fatalf("--images-json should not be empty")
 	}
+	if startOpts.operator.RolloutPollInterval <= 0 || startOpts.operator.RolloutPollInterval >= startOpts.operator.RolloutTimeout {
+		klog.Fatalf("--rollout-poll-interval must be positive and less than --rollout-timeout, got %v and %v",
+			startOpts.operator.RolloutPollInterval, startOpts.operator.RolloutTimeout)
+	}
+	if startOpts.operator.ControllerReplicas < 1 {
+		klog.Fatalf("--controller-replicas must be at least 1, got %d", startOpts.operator.ControllerReplicas)
+	}
+	switch startOpts.operator.ImagePullPolicy {
+	case "", v1.PullAlways, v1.PullIfNotPresent, v1.PullNever:
+	default:
+		klog.Fatalf("--image-pull-policy must be one of Always, IfNotPresent or Never, got %q", startOpts.operator.ImagePullPolicy)
+	}
+	if err := validateLeaderElectionDurations(startOpts.leaderElectLeaseDuration, startOpts.leaderElectRenewDeadline, startOpts.leaderElectRetryPeriod); err != nil {
+		klog.Fatal(err)
+	}
 
-	cb, err := NewClientBuilder(startOpts.kubeconfig)
+	cb, err := NewClientBuilder(startOpts.kubeconfig, startOpts.kubeAPIQPS, startOpts.kubeAPIBurst)
 	if err != nil {
 		klog.Fatalf("error creating clients: %v", err)
 	}
-	stopCh := make(chan struct{})
+	// SIGTERM stops the operator: its in-flight syncs are drained first, then
+	// the leader lease is released so another replica takes over right away.
+	stopCh := signals.SetupSignalHandler().Done()
+	leaderElectionCtx, stopLeaderElection := context.WithCancel(context.Background())
+	leading := make(chan struct{})
+	operatorStopped := make(chan struct{})
+	go func() {
+		<-stopCh
+		select {
+		case <-leading:
+			<-operatorStopped
+		default:
+		}
+		stopLeaderElection()
+	}()
+
+	// The endpoints are served before the leader lease is acquired, so replicas
+	// waiting for it report ready too.
+	// The servers shut down once stopCh is closed, runStartCmd waits for them
+	// so in-flight requests are answered before the process exits.
+	var servers sync.WaitGroup
+	startOpts.operator.Health = &operator.Health{}
+	if startOpts.healthAddr != "" {
+		servers.Add(1)
+		go func() {
+			defer servers.Done()
+			if err := operator.ServeHealth(startOpts.healthAddr, startOpts.operator.Health, stopCh); err != nil {
+				klog.Fatalf("Health server failed: %v", err)
+			}
+		}()
+	}
 
-	leaderelection.RunOrDie(context.TODO(), leaderelection.LeaderElectionConfig{
-		Lock:          CreateResourceLock(cb, componentNamespace, componentName),
-		LeaseDuration: LeaseDuration,
-		RenewDeadline: RenewDeadline,
-		RetryPeriod:   RetryPeriod,
+	leaderelection.RunOrDie(leaderElectionCtx, leaderelection.LeaderElectionConfig{
+		Lock:            CreateResourceLock(cb, componentNamespace, startOpts.leaderElectResourceName),
+		LeaseDuration:   startOpts.leaderElectLeaseDuration,
+		RenewDeadline:   startOpts.leaderElectRenewDeadline,
+		RetryPeriod:     startOpts.leaderElectRetryPeriod,
+		ReleaseOnCancel: true,
 		Callbacks: leaderelection.LeaderCallbacks{
 			OnStartedLeading: func(ctx context.Context) {
+				close(leading)
 				ctrlCtx := CreateControllerContext(cb, stopCh, componentNamespace)
-				startControllers(ctrlCtx)
+				operatorDone := startControllers(ctrlCtx)
 				ctrlCtx.KubeNamespacedInformerFactory.Start(ctrlCtx.Stop)
 				ctrlCtx.ConfigInformerFactory.Start(ctrlCtx.Stop)
 				initMachineAPIInformers(ctrlCtx)
-				startMetricsCollectionAndServer(ctrlCtx)
+				startMetricsCollectionAndServer(ctrlCtx, &servers)
 				close(ctrlCtx.InformersStarted)
 
-				select {}
+				<-operatorDone
+				close(operatorStopped)
 			},
 			OnStoppedLeading: func() {
-				klog.Fatalf("Leader election lost")
+				select {
+				case <-stopCh:
+					klog.Info("Stopped, leader lease released")
+				default:
+					klog.Fatalf("Leader election lost")
+				}
 			},
 		},
 	})
-	panic("unreachable")
+	servers.Wait()
 }
 
 func initMachineAPIInformers(ctx *ControllerContext) {
@@ -108,36 +215,50 @@ func initMachineAPIInformers(ctx *ControllerContext) {
 	klog.Info("Synced up machine api informer caches")
 }
 
-func initRecorder(kubeClient kubernetes.Interface) record.EventRecorder {
+func initRecorder(kubeClient kubernetes.Interface, component string) record.EventRecorder {
 	eventRecorderScheme := runtime.NewScheme()
 	osconfigv1.Install(eventRecorderScheme)
+	v1.AddToScheme(eventRecorderScheme)
 	eventBroadcaster := record.NewBroadcaster()
 	eventBroadcaster.StartLogging(klog.Infof)
 	eventBroadcaster.StartRecordingToSink(&coreclientsetv1.EventSinkImpl{Interface: kubeClient.CoreV1().Events("")})
-	return eventBroadcaster.NewRecorder(eventRecorderScheme, v1.EventSource{Component: "machineapioperator"})
+	return eventBroadcaster.NewRecorder(eventRecorderScheme, v1.EventSource{Component: component})
 }
 
-func startControllers(ctx *ControllerContext) {
+// startControllers starts the operator, the returned channel is closed once it
+// stopped and drained its in-flight syncs.
+func startControllers(ctx *ControllerContext) <-chan struct{} {
 	kubeClient := ctx.ClientBuilder.KubeClientOrDie(componentName)
-	recorder := initRecorder(kubeClient)
-	go operator.New(
+	recorder := initRecorder(kubeClient, startOpts.eventComponent)
+	optr := operator.New(
 		componentNamespace, componentName,
-		startOpts.imagesFile,
+		startOpts.imagesFiles,
 		config,
+		startOpts.operator,
 		ctx.KubeNamespacedInformerFactory.Apps().V1().Deployments(),
 		ctx.KubeNamespacedInformerFactory.Apps().V1().DaemonSets(),
 		ctx.ConfigInformerFactory.Config().V1().FeatureGates(),
 		ctx.KubeNamespacedInformerFactory.Admissionregistration().V1().ValidatingWebhookConfigurations(),
 		ctx.KubeNamespacedInformerFactory.Admissionregistration().V1().MutatingWebhookConfigurations(),
 		ctx.ConfigInformerFactory.Config().V1().Proxies(),
+		ctx.ConfigInformerFactory.Config().V1().Infrastructures(),
+		ctx.ConfigInformerFactory.Config().V1().ClusterOperators(),
+		ctx.KubeNamespacedInformerFactory.Core().V1().Secrets(),
+		ctx.KubeNamespacedInformerFactory.Core().V1().ConfigMaps(),
 		ctx.ClientBuilder.KubeClientOrDie(componentName),
 		ctx.ClientBuilder.OpenshiftClientOrDie(componentName),
 		ctx.ClientBuilder.DynamicClientOrDie(componentName),
 		recorder,
-	).Run(1, ctx.Stop)
+	)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		optr.Run(1, ctx.Stop)
+	}()
+	return done
 }
 
-func startMetricsCollectionAndServer(ctx *ControllerContext) {
+func startMetricsCollectionAndServer(ctx *ControllerContext, servers *sync.WaitGroup) {
 	machineInformer := ctx.MachineInformerFactory.Machine().V1beta1().Machines()
 	machinesetInformer := ctx.MachineInformerFactory.Machine().V1beta1().MachineSets()
 	machineMetricsCollector := metrics.NewMachineCollector(
@@ -145,6 +266,9 @@ func startMetricsCollectionAndServer(ctx *ControllerContext) {
 		machinesetInformer,
 		componentNamespace)
 	prometheus.MustRegister(machineMetricsCollector)
+	metrics.InitializeMachineAPIOperatorMetrics()
+	info := version.Get()
+	metrics.ObserveMachineAPIOperatorBuildInfo(info.Version, info.Commit, info.BuildDate)
 	metricsPort := defaultMetricsPort
 	if port, ok := os.LookupEnv("METRICS_PORT"); ok {
 		v, err := strconv.Atoi(port)
@@ -154,16 +278,64 @@ func startMetricsCollectionAndServer(ctx *ControllerContext) {
 		metricsPort = v
 	}
 	klog.V(4).Info("Starting server to serve prometheus metrics")
-	go startHTTPMetricServer(fmt.Sprintf("localhost:%d", metricsPort))
+	servers.Add(1)
+	go func() {
+		defer servers.Done()
+		startHTTPMetricServer(fmt.Sprintf("localhost:%d", metricsPort), ctx.Stop)
+	}()
 }
 
-func startHTTPMetricServer(metricsPort string) {
+// startHTTPMetricServer serves the metrics and version endpoints until stopCh is closed.
+func startHTTPMetricServer(metricsPort string, stopCh <-chan struct{}) {
 	mux := http.NewServeMux()
 	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/version", serveVersion)
 
 	server := &http.Server{
 		Addr:    metricsPort,
 		Handler: mux,
 	}
-	klog.Fatal(server.ListenAndServe())
+	if err := operator.ServeUntilStopped(server, stopCh); err != nil {
+		klog.Fatalf("Metrics server failed: %v", err)
+	}
+}
+
+// serveVersion responds with the build metadata of the operator.
+func serveVersion(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(version.Get()); err != nil {
+		klog.Errorf("Error writing version response: %v", err)
+	}
+}
+
+// jsonFlag is a flag whose value is decoded from JSON into target.
+type jsonFlag struct {
+	target   interface{}
+	typeName string
+}
+
+func newJSONFlag(target interface{}, typeName string) *jsonFlag {
+	return &jsonFlag{target: target, typeName: typeName}
+}
+
+func (f *jsonFlag) String() string {
+	if f.target == nil {
+		return ""
+	}
+	data, err := json.Marshal(f.target)
+	if err != nil || string(data) == "null" {
+		return ""
+	}
+	return string(data)
+}
+
+func (f *jsonFlag) Set(value string) error {
+	if err := json.Unmarshal([]byte(value), f.target); err != nil {
+		return fmt.Errorf("must be valid JSON %s: %w", f.typeName, err)
+	}
+	return nil
+}
+
+func (f *jsonFlag) Type() string {
+	return f.typeName
 }
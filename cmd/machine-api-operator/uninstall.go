@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+
+	"github.com/openshift/machine-api-operator/pkg/operator"
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/rest"
+	"k8s.io/klog/v2"
+)
+
+var (
+	uninstallCmd = &cobra.Command{
+		Use:   "uninstall",
+		Short: "Deletes the components managed by Machine API Operator",
+		Long: `Deletes the machine API controllers, the termination handler and the webhook configurations
+managed by Machine API Operator. Stop the operator first, otherwise it recreates them.`,
+		Run: runUninstallCmd,
+	}
+
+	uninstallOpts struct {
+		kubeconfig string
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(uninstallCmd)
+	uninstallCmd.PersistentFlags().StringVar(&uninstallOpts.kubeconfig, "kubeconfig", "", "Kubeconfig file to access a remote cluster (testing only)")
+}
+
+func runUninstallCmd(cmd *cobra.Command, args []string) {
+	cb, err := NewClientBuilder(uninstallOpts.kubeconfig, rest.DefaultQPS, rest.DefaultBurst)
+	if err != nil {
+		klog.Fatalf("error creating clients: %v", err)
+	}
+
+	if err := operator.DeleteOperands(context.Background(), cb.KubeClientOrDie(componentName), componentNamespace); err != nil {
+		klog.Fatalf("Error deleting Machine API Operator components: %v", err)
+	}
+}
@@ -25,8 +25,10 @@ func runVersionCmd(cmd *cobra.Command, args []string) {
 	flag.Set("logtostderr", "true")
 	flag.Parse()
 
-	program := "MachineAPIOperator"
-	version := "v" + version.Version.String()
+	fmt.Println(versionString())
+}
 
-	fmt.Println(program, version)
+func versionString() string {
+	info := version.Get()
+	return fmt.Sprintf("MachineAPIOperator v%s (commit: %s, built: %s)", version.Version.String(), info.Commit, info.BuildDate)
 }
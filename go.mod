@@ -4,6 +4,7 @@ go 1.13
 
 require (
 	github.com/blang/semver v3.5.1+incompatible
+	github.com/fsnotify/fsnotify v1.4.9
 	github.com/google/gofuzz v1.1.0
 	github.com/google/uuid v1.1.2
 	github.com/onsi/ginkgo v1.14.1
@@ -14,11 +15,13 @@ require (
 	github.com/openshift/library-go v0.0.0-20201215165635-4ee79b1caed5
 	github.com/operator-framework/operator-sdk v0.5.1-0.20190301204940-c2efe6f74e7b
 	github.com/prometheus/client_golang v1.7.1
+	github.com/prometheus/client_model v0.2.0
 	github.com/spf13/cobra v1.1.1
 	github.com/spf13/pflag v1.0.5
 	github.com/stretchr/testify v1.6.1
 	github.com/vmware/govmomi v0.22.2
 	golang.org/x/net v0.0.0-20201110031124-69a78807bb2b
+	golang.org/x/time v0.0.0-20200630173020-3af7569d3a1e
 	gopkg.in/gcfg.v1 v1.2.3
 	k8s.io/api v0.20.0
 	k8s.io/apimachinery v0.20.0
@@ -19,7 +19,14 @@ if [ -z ${VERSION_OVERRIDE+a} ]; then
 	VERSION_OVERRIDE=$(git describe --abbrev=8 --dirty --always)
 fi
 
+if [ -z ${COMMIT_OVERRIDE+a} ]; then
+	COMMIT_OVERRIDE=$(git rev-parse HEAD)
+fi
+
+BUILD_DATE=$(date -u +'%Y-%m-%dT%H:%M:%SZ')
+
 GLDFLAGS+="-extldflags '-static' -X ${REPO}/pkg/version.Raw=${VERSION_OVERRIDE}"
+GLDFLAGS+=" -X ${REPO}/pkg/version.Commit=${COMMIT_OVERRIDE} -X ${REPO}/pkg/version.BuildDate=${BUILD_DATE}"
 
 eval $(go env)
 
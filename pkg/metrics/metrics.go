@@ -1,10 +1,13 @@
 package metrics
 
 import (
+	"encoding/json"
+
 	mapiv1beta1 "github.com/openshift/machine-api-operator/pkg/apis/machine/v1beta1"
 	machineinformers "github.com/openshift/machine-api-operator/pkg/generated/informers/externalversions/machine/v1beta1"
 	machinelisters "github.com/openshift/machine-api-operator/pkg/generated/listers/machine/v1beta1"
 	"github.com/prometheus/client_golang/prometheus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/klog/v2"
 	"sigs.k8s.io/controller-runtime/pkg/metrics"
@@ -35,6 +38,9 @@ var (
 	// MachineSetStatusReplicasDesc is the information of the Machineset's status for replicas.
 	MachineSetStatusReplicasDesc = prometheus.NewDesc("mapi_machine_set_status_replicas", "Information of the mapi managed Machineset's status for replicas", []string{"name", "namespace"}, nil)
 
+	// MachineSetSpecReplicasDesc is the desired replicas of the Machineset, to be compared with its ready replicas.
+	MachineSetSpecReplicasDesc = prometheus.NewDesc("mapi_machine_set_spec_replicas", "Desired replicas of the mapi managed Machineset", []string{"name", "namespace", "provider"}, nil)
+
 	// MachineCollectorUp is a Prometheus metric, which reports reflects successful collection and reporting of all the metrics
 	MachineCollectorUp = prometheus.NewGaugeVec(prometheus.GaugeOpts{
 		Name: "mapi_mao_collector_up",
@@ -117,6 +123,7 @@ func (mc *MachineCollector) Collect(ch chan<- prometheus.Metric) {
 func (mc MachineCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- MachineCountDesc
 	ch <- MachineSetCountDesc
+	ch <- MachineSetSpecReplicasDesc
 }
 
 // Collect implements the prometheus.Collector interface.
@@ -198,7 +205,33 @@ func (mc MachineCollector) collectMachineSetMetrics(ch chan<- prometheus.Metric)
 			float64(machineSet.Status.Replicas),
 			machineSet.Name, machineSet.Namespace,
 		)
+		if machineSet.Spec.Replicas != nil {
+			ch <- prometheus.MustNewConstMetric(
+				MachineSetSpecReplicasDesc,
+				prometheus.GaugeValue,
+				float64(*machineSet.Spec.Replicas),
+				machineSet.Name, machineSet.Namespace, machineSetProvider(machineSet),
+			)
+		}
+	}
+}
+
+// machineSetProvider returns the kind of the provider spec of the machines of
+// the given MachineSet, e.g. AWSMachineProviderConfig, or an empty string if
+// it can't be determined.
+func machineSetProvider(machineSet *mapiv1beta1.MachineSet) string {
+	value := machineSet.Spec.Template.Spec.ProviderSpec.Value
+	if value == nil {
+		return ""
+	}
+	if value.Object != nil {
+		return value.Object.GetObjectKind().GroupVersionKind().Kind
+	}
+	var typeMeta metav1.TypeMeta
+	if err := json.Unmarshal(value.Raw, &typeMeta); err != nil {
+		return ""
 	}
+	return typeMeta.Kind
 }
 
 func (mc MachineCollector) listMachines() ([]*mapiv1beta1.Machine, error) {
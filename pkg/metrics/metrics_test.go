@@ -1,6 +1,16 @@
 package metrics
 
-import "testing"
+import (
+	"testing"
+
+	mapiv1beta1 "github.com/openshift/machine-api-operator/pkg/apis/machine/v1beta1"
+	machinelisters "github.com/openshift/machine-api-operator/pkg/generated/listers/machine/v1beta1"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/cache"
+)
 
 func TestStringPointerDeref(t *testing.T) {
 	value := "test"
@@ -23,3 +33,108 @@ func TestStringPointerDeref(t *testing.T) {
 		}
 	}
 }
+
+func TestMachineSetProvider(t *testing.T) {
+	testCases := []struct {
+		name     string
+		value    *runtime.RawExtension
+		expected string
+	}{
+		{
+			name:     "no provider spec",
+			expected: "",
+		},
+		{
+			name:     "raw provider spec",
+			value:    &runtime.RawExtension{Raw: []byte(`{"kind":"AWSMachineProviderConfig","apiVersion":"awsproviderconfig.openshift.io/v1beta1"}`)},
+			expected: "AWSMachineProviderConfig",
+		},
+		{
+			name:     "invalid provider spec",
+			value:    &runtime.RawExtension{Raw: []byte(`not json`)},
+			expected: "",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			machineSet := &mapiv1beta1.MachineSet{}
+			machineSet.Spec.Template.Spec.ProviderSpec.Value = tc.value
+			if got := machineSetProvider(machineSet); got != tc.expected {
+				t.Errorf("Got: %v, expected: %v", got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestCollectMachineSetSpecReplicas(t *testing.T) {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	replicas := int32(3)
+	machineSet := &mapiv1beta1.MachineSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "workers", Namespace: "test-namespace"},
+		Spec:       mapiv1beta1.MachineSetSpec{Replicas: &replicas},
+	}
+	if err := indexer.Add(machineSet); err != nil {
+		t.Fatal(err)
+	}
+	mc := MachineCollector{
+		machineSetLister: machinelisters.NewMachineSetLister(indexer),
+		namespace:        "test-namespace",
+	}
+
+	ch := make(chan prometheus.Metric, 10)
+	mc.collectMachineSetMetrics(ch)
+	close(ch)
+
+	var found bool
+	for metric := range ch {
+		if metric.Desc() != MachineSetSpecReplicasDesc {
+			continue
+		}
+		found = true
+		var m dto.Metric
+		if err := metric.Write(&m); err != nil {
+			t.Fatal(err)
+		}
+		if got := m.GetGauge().GetValue(); got != 3 {
+			t.Errorf("Got: %v, expected: 3", got)
+		}
+	}
+	if !found {
+		t.Error("expected the desired replicas of the MachineSet to be collected")
+	}
+}
+
+func TestObserveMachineAPIOperatorSync(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	for _, collector := range []prometheus.Collector{
+		MachineAPIOperatorSyncDuration,
+		MachineAPIOperatorSyncSuccessTotal,
+		MachineAPIOperatorSyncFailedTotal,
+		MachineAPIOperatorSyncRetriesTotal,
+		MachineAPIOperatorBuildInfo,
+	} {
+		if err := registry.Register(collector); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	successTotal := func() float64 {
+		families, err := registry.Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, family := range families {
+			if family.GetName() == "mapi_mao_sync_success_total" {
+				return family.GetMetric()[0].GetCounter().GetValue()
+			}
+		}
+		t.Fatal("expected mapi_mao_sync_success_total to be gathered")
+		return 0
+	}
+
+	before := successTotal()
+	ObserveMachineAPIOperatorSyncSuccess()
+	if got := successTotal(); got != before+1 {
+		t.Errorf("Got: %v, expected: %v", got, before+1)
+	}
+}
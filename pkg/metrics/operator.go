@@ -0,0 +1,102 @@
+/*
+   Copyright 2020 The Machine API Operator authors
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	// MachineAPIOperatorSyncDuration is a Prometheus metric, which reports the time taken by a single operator sync
+	MachineAPIOperatorSyncDuration = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "mapi_mao_sync_duration_seconds",
+			Help:    "Number of seconds taken by a single Machine API Operator sync.",
+			Buckets: []float64{0.1, 0.5, 1, 5, 10, 30, 60, 120, 180, 240, 300, 600},
+		},
+	)
+
+	// MachineAPIOperatorSyncSuccessTotal is a Prometheus metric, which reports the number of successful operator syncs
+	MachineAPIOperatorSyncSuccessTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "mapi_mao_sync_success_total",
+			Help: "Number of successful Machine API Operator syncs.",
+		},
+	)
+
+	// MachineAPIOperatorSyncFailedTotal is a Prometheus metric, which reports the number of failed operator syncs
+	MachineAPIOperatorSyncFailedTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "mapi_mao_sync_failed_total",
+			Help: "Number of failed Machine API Operator syncs.",
+		},
+	)
+
+	// MachineAPIOperatorSyncRetriesTotal is a Prometheus metric, which reports the number of times a failed sync was requeued
+	MachineAPIOperatorSyncRetriesTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "mapi_mao_sync_retries_total",
+			Help: "Number of times a failed Machine API Operator sync was requeued.",
+		},
+	)
+
+	// MachineAPIOperatorBuildInfo is a Prometheus metric, which reports the build metadata of the running operator
+	MachineAPIOperatorBuildInfo = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "mapi_mao_build_info",
+			Help: "Build metadata of the running Machine API Operator. The value is always 1.",
+		}, []string{"version", "commit", "build_date"},
+	)
+)
+
+// InitializeMachineAPIOperatorMetrics registers the operator sync metrics
+// with the default Prometheus registry served by the operator.
+func InitializeMachineAPIOperatorMetrics() {
+	prometheus.MustRegister(
+		MachineAPIOperatorSyncDuration,
+		MachineAPIOperatorSyncSuccessTotal,
+		MachineAPIOperatorSyncFailedTotal,
+		MachineAPIOperatorSyncRetriesTotal,
+		MachineAPIOperatorBuildInfo,
+	)
+}
+
+// ObserveMachineAPIOperatorSyncDuration records the time taken by an operator sync.
+func ObserveMachineAPIOperatorSyncDuration(duration time.Duration) {
+	MachineAPIOperatorSyncDuration.Observe(duration.Seconds())
+}
+
+// ObserveMachineAPIOperatorSyncSuccess counts a successful operator sync.
+func ObserveMachineAPIOperatorSyncSuccess() {
+	MachineAPIOperatorSyncSuccessTotal.Inc()
+}
+
+// ObserveMachineAPIOperatorSyncFailed counts a failed operator sync.
+func ObserveMachineAPIOperatorSyncFailed() {
+	MachineAPIOperatorSyncFailedTotal.Inc()
+}
+
+// ObserveMachineAPIOperatorSyncRetry counts a failed operator sync being requeued.
+func ObserveMachineAPIOperatorSyncRetry() {
+	MachineAPIOperatorSyncRetriesTotal.Inc()
+}
+
+// ObserveMachineAPIOperatorBuildInfo reports the build metadata of the running operator.
+func ObserveMachineAPIOperatorBuildInfo(version, commit, buildDate string) {
+	MachineAPIOperatorBuildInfo.WithLabelValues(version, commit, buildDate).Set(1)
+}
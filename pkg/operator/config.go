@@ -4,9 +4,19 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"os"
 	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
 
 	configv1 "github.com/openshift/api/config/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/klog/v2"
 )
 
 const (
@@ -21,17 +31,45 @@ type Provider string
 // OperatorConfig contains configuration for MAO
 type OperatorConfig struct {
 	TargetNamespace string `json:"targetNamespace"`
+	PlatformType    configv1.PlatformType
 	Controllers     Controllers
 	Proxy           *configv1.Proxy
+	// ControllerReplicas is the number of replicas of the machine-api-controllers
+	// Deployment. Zero renders a single replica.
+	ControllerReplicas int32 `json:"controllerReplicas,omitempty"`
+	// Tolerations are added to the tolerations of the machine-api-controllers
+	// pods, which already tolerate the master taint.
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+	// TopologySpreadConstraints are set on the machine-api-controllers pods,
+	// e.g. to spread their replicas across zones.
+	TopologySpreadConstraints []corev1.TopologySpreadConstraint `json:"topologySpreadConstraints,omitempty"`
+	// ExtraVolumes are added to the machine-api-controllers pods and
+	// ExtraVolumeMounts to their machine-controller container, e.g. for
+	// additional credentials of a provider.
+	ExtraVolumes      []corev1.Volume      `json:"extraVolumes,omitempty"`
+	ExtraVolumeMounts []corev1.VolumeMount `json:"extraVolumeMounts,omitempty"`
+	// InitContainers are run in the machine-api-controllers pods before the
+	// controllers start, e.g. to fetch metadata a provider needs.
+	InitContainers []corev1.Container `json:"initContainers,omitempty"`
+	// ImagePullPolicy is set on the containers of the machine-api-controllers
+	// pods. When empty the API server default applies.
+	ImagePullPolicy corev1.PullPolicy `json:"imagePullPolicy,omitempty"`
+	// Resources replace the default resource requirements of the containers
+	// of the operands, keyed by container name. The kube-rbac-proxy key applies
+	// to all of the kube-rbac-proxy containers.
+	Resources map[string]corev1.ResourceRequirements `json:"resources,omitempty"`
+	// AllowedNamespaces are the namespaces operands may be written into.
+	// When empty only TargetNamespace is allowed.
+	AllowedNamespaces []string `json:"allowedNamespaces,omitempty"`
 }
 
 type Controllers struct {
-	Provider           string
-	MachineSet         string
-	NodeLink           string
-	MachineHealthCheck string
-	KubeRBACProxy      string
-	TerminationHandler string
+	Provider           string `json:"provider"`
+	MachineSet         string `json:"machineSet"`
+	NodeLink           string `json:"nodeLink"`
+	MachineHealthCheck string `json:"machineHealthCheck"`
+	KubeRBACProxy      string `json:"kubeRBACProxy"`
+	TerminationHandler string `json:"terminationHandler"`
 }
 
 // Images allows build systems to inject images for MAO components
@@ -49,79 +87,229 @@ type Images struct {
 	KubeRBACProxy                 string `json:"kubeRBACProxy"`
 }
 
+// getProviderFromInfrastructure returns the platform of the cluster. The deprecated
+// status.platform is used when status.platformStatus is not set, and the two must
+// agree when both are.
 func getProviderFromInfrastructure(infra *configv1.Infrastructure) (configv1.PlatformType, error) {
-	if infra.Status.Platform == "" {
-		return "", fmt.Errorf("no platform provider found on install config")
+	platform := infra.Status.Platform
+	if infra.Status.PlatformStatus != nil && infra.Status.PlatformStatus.Type != "" {
+		if platform != "" && platform != infra.Status.PlatformStatus.Type {
+			return "", NewPermanentError(fmt.Errorf("%w: ambiguous platform provider on install config: platform is %q but platformStatus type is %q",
+				ErrInvalidProvider, platform, infra.Status.PlatformStatus.Type))
+		}
+		platform = infra.Status.PlatformStatus.Type
 	}
-	return infra.Status.Platform, nil
+	if platform == "" {
+		return "", NewPermanentError(fmt.Errorf("%w: no platform provider found on install config", ErrInvalidProvider))
+	}
+	return platform, nil
 }
 
-func getImagesFromJSONFile(filePath string) (*Images, error) {
-	data, err := ioutil.ReadFile(filepath.Clean(filePath))
-	if err != nil {
-		return nil, err
+// getImagesFromJSONFiles reads the images from each of the given files in order,
+// fields set in a later file override the ones read from the previous files.
+// The first file is required, any missing file after it is skipped.
+// Environment variables from imageEnvOverrides take precedence over all files.
+func getImagesFromJSONFiles(filePaths []string) (*Images, error) {
+	if len(filePaths) == 0 {
+		return nil, fmt.Errorf("%w: no images file provided", ErrImagesFileMissing)
 	}
 
 	var i Images
-	if err := json.Unmarshal(data, &i); err != nil {
-		return nil, err
+	for n, filePath := range filePaths {
+		data, err := readImagesFile(filePath, n > 0)
+		if os.IsNotExist(err) {
+			if n > 0 {
+				klog.Warningf("Skipping missing images file %q", filePath)
+				continue
+			}
+			return nil, fmt.Errorf("%w: %v", ErrImagesFileMissing, err)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// Unmarshalling into the same struct only overrides the fields present in data.
+		if err := json.Unmarshal(data, &i); err != nil {
+			return nil, fmt.Errorf("failed to parse images file %q: %w", filePath, err)
+		}
 	}
+	applyImageEnvOverrides(&i)
 	return &i, nil
 }
 
+// imageEnvOverrides maps the environment variables which override an image to the
+// field of Images they set.
+var imageEnvOverrides = map[string]func(*Images) *string{
+	"MAO_IMAGE_MACHINE_API_OPERATOR":             func(i *Images) *string { return &i.MachineAPIOperator },
+	"MAO_IMAGE_CLUSTER_API_CONTROLLER_AWS":       func(i *Images) *string { return &i.ClusterAPIControllerAWS },
+	"MAO_IMAGE_CLUSTER_API_CONTROLLER_OPENSTACK": func(i *Images) *string { return &i.ClusterAPIControllerOpenStack },
+	"MAO_IMAGE_CLUSTER_API_CONTROLLER_LIBVIRT":   func(i *Images) *string { return &i.ClusterAPIControllerLibvirt },
+	"MAO_IMAGE_CLUSTER_API_CONTROLLER_BAREMETAL": func(i *Images) *string { return &i.ClusterAPIControllerBareMetal },
+	"MAO_IMAGE_CLUSTER_API_CONTROLLER_AZURE":     func(i *Images) *string { return &i.ClusterAPIControllerAzure },
+	"MAO_IMAGE_CLUSTER_API_CONTROLLER_GCP":       func(i *Images) *string { return &i.ClusterAPIControllerGCP },
+	"MAO_IMAGE_CLUSTER_API_CONTROLLER_OVIRT":     func(i *Images) *string { return &i.ClusterAPIControllerOvirt },
+	"MAO_IMAGE_CLUSTER_API_CONTROLLER_VSPHERE":   func(i *Images) *string { return &i.ClusterAPIControllerVSphere },
+	"MAO_IMAGE_CLUSTER_API_CONTROLLER_KUBEVIRT":  func(i *Images) *string { return &i.ClusterAPIControllerKubevirt },
+	"MAO_IMAGE_KUBE_RBAC_PROXY":                  func(i *Images) *string { return &i.KubeRBACProxy },
+}
+
+// applyImageEnvOverrides sets the images from any non-empty environment variable
+// in imageEnvOverrides.
+func applyImageEnvOverrides(i *Images) {
+	for env, field := range imageEnvOverrides {
+		if image := os.Getenv(env); image != "" {
+			klog.V(2).Infof("Overriding image from %s: %s", env, image)
+			*field(i) = image
+		}
+	}
+}
+
+// defaultImages are the images used for any field left empty by the images
+// files and their environment overrides.
+var defaultImages = Images{
+	MachineAPIOperator:            "quay.io/openshift/origin-machine-api-operator:latest",
+	ClusterAPIControllerAWS:       "quay.io/openshift/origin-aws-machine-controllers:latest",
+	ClusterAPIControllerOpenStack: "quay.io/openshift/origin-openstack-machine-controllers:latest",
+	ClusterAPIControllerLibvirt:   "quay.io/openshift/origin-libvirt-machine-controllers:latest",
+	ClusterAPIControllerBareMetal: "quay.io/openshift/origin-baremetal-machine-controllers:latest",
+	ClusterAPIControllerAzure:     "quay.io/openshift/origin-azure-machine-controllers:latest",
+	ClusterAPIControllerGCP:       "quay.io/openshift/origin-gcp-machine-controllers:latest",
+	ClusterAPIControllerOvirt:     "quay.io/openshift/origin-ovirt-machine-controllers:latest",
+	ClusterAPIControllerVSphere:   "quay.io/openshift/origin-machine-api-operator:latest",
+	ClusterAPIControllerKubevirt:  "quay.io/openshift/origin-kubevirt-machine-controllers:latest",
+	KubeRBACProxy:                 "quay.io/openshift/origin-kube-rbac-proxy:latest",
+}
+
+// applyImageDefaults sets every empty field of i from defaultImages and returns
+// the JSON names of the fields it defaulted.
+func applyImageDefaults(i *Images) []string {
+	var defaulted []string
+	value := reflect.ValueOf(i).Elem()
+	defaults := reflect.ValueOf(defaultImages)
+	for n := 0; n < value.NumField(); n++ {
+		if value.Field(n).String() != "" {
+			continue
+		}
+		value.Field(n).SetString(defaults.Field(n).String())
+		defaulted = append(defaulted, value.Type().Field(n).Tag.Get("json"))
+	}
+	return defaulted
+}
+
+// imageDigestRegexp matches a pullspec pinned by a sha256 digest.
+var imageDigestRegexp = regexp.MustCompile(`@sha256:[a-f0-9]{64}$`)
+
+// validateImageDigests returns an error listing the images of controllers which
+// are referenced by tag rather than pinned by digest. The no-op placeholder is skipped.
+func validateImageDigests(controllers Controllers) error {
+	unpinned := sets.NewString()
+	for _, image := range []string{
+		controllers.Provider,
+		controllers.MachineSet,
+		controllers.NodeLink,
+		controllers.MachineHealthCheck,
+		controllers.KubeRBACProxy,
+		controllers.TerminationHandler,
+	} {
+		if image != clusterAPIControllerNoOp && !imageDigestRegexp.MatchString(image) {
+			unpinned.Insert(image)
+		}
+	}
+	if unpinned.Len() > 0 {
+		return NewPermanentError(fmt.Errorf("images are not pinned by digest: %s", strings.Join(unpinned.List(), ", ")))
+	}
+	return nil
+}
+
+// imagesFileReadBackoff bounds the retries of reading an images file, which can be
+// briefly unreadable while the configmap it is mounted from is being updated.
+var imagesFileReadBackoff = wait.Backoff{
+	Steps:    4,
+	Duration: 50 * time.Millisecond,
+	Factor:   2.0,
+}
+
+// readImagesFile reads filePath, retrying with a short backoff on errors.
+// A missing optional file is returned right away.
+func readImagesFile(filePath string, optional bool) ([]byte, error) {
+	var data []byte
+	err := retry.OnError(imagesFileReadBackoff, func(err error) bool {
+		return !(optional && os.IsNotExist(err))
+	}, func() error {
+		var err error
+		data, err = ioutil.ReadFile(filepath.Clean(filePath))
+		return err
+	})
+	return data, err
+}
+
+// providerControllerImages maps a platform to the image of its machine controller.
+var providerControllerImages = map[configv1.PlatformType]func(Images) string{
+	configv1.AWSPlatformType:       func(i Images) string { return i.ClusterAPIControllerAWS },
+	configv1.LibvirtPlatformType:   func(i Images) string { return i.ClusterAPIControllerLibvirt },
+	configv1.OpenStackPlatformType: func(i Images) string { return i.ClusterAPIControllerOpenStack },
+	configv1.AzurePlatformType:     func(i Images) string { return i.ClusterAPIControllerAzure },
+	configv1.GCPPlatformType:       func(i Images) string { return i.ClusterAPIControllerGCP },
+	configv1.BareMetalPlatformType: func(i Images) string { return i.ClusterAPIControllerBareMetal },
+	configv1.OvirtPlatformType:     func(i Images) string { return i.ClusterAPIControllerOvirt },
+	configv1.VSpherePlatformType:   func(i Images) string { return i.ClusterAPIControllerVSphere },
+	configv1.KubevirtPlatformType:  func(i Images) string { return i.ClusterAPIControllerKubevirt },
+	kubemarkPlatform:               func(Images) string { return clusterAPIControllerKubemark },
+}
+
+// terminationHandlerImages maps a platform to the image of its termination handler.
+// Only platforms with interruptible instances are listed.
+var terminationHandlerImages = map[configv1.PlatformType]func(Images) string{
+	configv1.AWSPlatformType:   func(i Images) string { return i.ClusterAPIControllerAWS },
+	configv1.GCPPlatformType:   func(i Images) string { return i.ClusterAPIControllerGCP },
+	configv1.AzurePlatformType: func(i Images) string { return i.ClusterAPIControllerAzure },
+}
+
+// providerCredentialsSecrets maps a platform to the secret in the target
+// namespace holding the cloud credentials of its machine controller, as
+// requested in install/0000_30_machine-api-operator_00_credentials-request.yaml.
+// Platforms without credentials requests are not listed.
+var providerCredentialsSecrets = map[configv1.PlatformType]string{
+	configv1.AWSPlatformType:       "aws-cloud-credentials",
+	configv1.AzurePlatformType:     "azure-cloud-credentials",
+	configv1.OpenStackPlatformType: "openstack-cloud-credentials",
+	configv1.GCPPlatformType:       "gcp-cloud-credentials",
+	configv1.OvirtPlatformType:     "ovirt-credentials",
+	configv1.VSpherePlatformType:   "vsphere-cloud-credentials",
+	configv1.KubevirtPlatformType:  "kubevirt-credentials",
+}
+
 func getProviderControllerFromImages(platform configv1.PlatformType, images Images) (string, error) {
-	switch platform {
-	case configv1.AWSPlatformType:
-		return images.ClusterAPIControllerAWS, nil
-	case configv1.LibvirtPlatformType:
-		return images.ClusterAPIControllerLibvirt, nil
-	case configv1.OpenStackPlatformType:
-		return images.ClusterAPIControllerOpenStack, nil
-	case configv1.AzurePlatformType:
-		return images.ClusterAPIControllerAzure, nil
-	case configv1.GCPPlatformType:
-		return images.ClusterAPIControllerGCP, nil
-	case configv1.BareMetalPlatformType:
-		return images.ClusterAPIControllerBareMetal, nil
-	case configv1.OvirtPlatformType:
-		return images.ClusterAPIControllerOvirt, nil
-	case configv1.VSpherePlatformType:
-		return images.ClusterAPIControllerVSphere, nil
-	case configv1.KubevirtPlatformType:
-		return images.ClusterAPIControllerKubevirt, nil
-	case kubemarkPlatform:
-		return clusterAPIControllerKubemark, nil
-	default:
+	image, ok := providerControllerImages[platform]
+	if !ok {
 		return clusterAPIControllerNoOp, nil
 	}
+	if image(images) == "" {
+		return "", NewPermanentError(fmt.Errorf("failed getting %s provider controller image. It is empty", platform))
+	}
+	return image(images), nil
 }
 
 // getTerminationHandlerFromImages returns the image to use for the Termination Handler DaemonSet
 // based on the platform provided.
 // Defaults to NoOp if not supported by the platform.
 func getTerminationHandlerFromImages(platform configv1.PlatformType, images Images) (string, error) {
-	switch platform {
-	case configv1.AWSPlatformType:
-		return images.ClusterAPIControllerAWS, nil
-	case configv1.GCPPlatformType:
-		return images.ClusterAPIControllerGCP, nil
-	case configv1.AzurePlatformType:
-		return images.ClusterAPIControllerAzure, nil
-	default:
-		return clusterAPIControllerNoOp, nil
+	if image, ok := terminationHandlerImages[platform]; ok {
+		return image(images), nil
 	}
+	return clusterAPIControllerNoOp, nil
 }
 
 func getMachineAPIOperatorFromImages(images Images) (string, error) {
 	if images.MachineAPIOperator == "" {
-		return "", fmt.Errorf("failed gettingMachineAPIOperator image. It is empty")
+		return "", NewPermanentError(fmt.Errorf("failed gettingMachineAPIOperator image. It is empty"))
 	}
 	return images.MachineAPIOperator, nil
 }
 
 func getKubeRBACProxyFromImages(images Images) (string, error) {
 	if images.KubeRBACProxy == "" {
-		return "", fmt.Errorf("failed getting kubeRBACProxy image. It is empty")
+		return "", NewPermanentError(fmt.Errorf("failed getting kubeRBACProxy image. It is empty"))
 	}
 	return images.KubeRBACProxy, nil
 }
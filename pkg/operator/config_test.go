@@ -1,7 +1,15 @@
 package operator
 
 import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	configv1 "github.com/openshift/api/config/v1"
 )
@@ -108,37 +116,226 @@ func TestGetProviderFromInfrastructure(t *testing.T) {
 	}
 }
 
-func TestGetImagesFromJSONFile(t *testing.T) {
-	img, err := getImagesFromJSONFile(imagesJSONFile)
+func TestGetProviderFromInfrastructurePlatformStatus(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      configv1.InfrastructureStatus
+		expected    configv1.PlatformType
+		expectedErr bool
+	}{{
+		name: "platformStatus only",
+		status: configv1.InfrastructureStatus{
+			PlatformStatus: &configv1.PlatformStatus{Type: configv1.GCPPlatformType},
+		},
+		expected: configv1.GCPPlatformType,
+	}, {
+		name: "platform and platformStatus agree",
+		status: configv1.InfrastructureStatus{
+			Platform:       configv1.AzurePlatformType,
+			PlatformStatus: &configv1.PlatformStatus{Type: configv1.AzurePlatformType},
+		},
+		expected: configv1.AzurePlatformType,
+	}, {
+		name: "platform and platformStatus disagree",
+		status: configv1.InfrastructureStatus{
+			Platform:       configv1.AWSPlatformType,
+			PlatformStatus: &configv1.PlatformStatus{Type: configv1.AzurePlatformType},
+		},
+		expectedErr: true,
+	}, {
+		name:        "no platform",
+		status:      configv1.InfrastructureStatus{},
+		expectedErr: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			res, err := getProviderFromInfrastructure(&configv1.Infrastructure{Status: test.status})
+			if test.expectedErr != (err != nil) {
+				t.Fatalf("failed getProviderFromInfrastructure. Expected error: %v, got: %v", test.expectedErr, err)
+			}
+			if test.expected != res {
+				t.Errorf("failed getProviderFromInfrastructure. Expected: %q, got: %q", test.expected, res)
+			}
+		})
+	}
+}
+
+func TestGetImagesFromJSONFiles(t *testing.T) {
+	img, err := getImagesFromJSONFiles([]string{imagesJSONFile})
 	if err != nil {
-		t.Errorf("failed getImagesFromJSONFile")
+		t.Errorf("failed getImagesFromJSONFiles")
 	}
 	if img.ClusterAPIControllerAWS != expectedAWSImage {
-		t.Errorf("failed getImagesFromJSONFile. Expected: %s, got: %s", expectedAWSImage, img.ClusterAPIControllerAWS)
+		t.Errorf("failed getImagesFromJSONFiles. Expected: %s, got: %s", expectedAWSImage, img.ClusterAPIControllerAWS)
 	}
 	if img.ClusterAPIControllerLibvirt != expectedLibvirtImage {
-		t.Errorf("failed getImagesFromJSONFile. Expected: %s, got: %s", expectedLibvirtImage, img.ClusterAPIControllerLibvirt)
+		t.Errorf("failed getImagesFromJSONFiles. Expected: %s, got: %s", expectedLibvirtImage, img.ClusterAPIControllerLibvirt)
 	}
 	if img.ClusterAPIControllerOpenStack != expectedOpenstackImage {
-		t.Errorf("failed getImagesFromJSONFile. Expected: %s, got: %s", expectedOpenstackImage, img.ClusterAPIControllerOpenStack)
+		t.Errorf("failed getImagesFromJSONFiles. Expected: %s, got: %s", expectedOpenstackImage, img.ClusterAPIControllerOpenStack)
 	}
 	if img.ClusterAPIControllerBareMetal != expectedBareMetalImage {
-		t.Errorf("failed getImagesFromJSONFile. Expected: %s, got: %s", expectedBareMetalImage, img.ClusterAPIControllerBareMetal)
+		t.Errorf("failed getImagesFromJSONFiles. Expected: %s, got: %s", expectedBareMetalImage, img.ClusterAPIControllerBareMetal)
 	}
 	if img.ClusterAPIControllerAzure != expectedAzureImage {
-		t.Errorf("failed getImagesFromJSONFile. Expected: %s, got: %s", expectedAzureImage, img.ClusterAPIControllerAzure)
+		t.Errorf("failed getImagesFromJSONFiles. Expected: %s, got: %s", expectedAzureImage, img.ClusterAPIControllerAzure)
 	}
 	if img.ClusterAPIControllerGCP != expectedGCPImage {
-		t.Errorf("failed getImagesFromJSONFile. Expected: %s, got: %s", expectedGCPImage, img.ClusterAPIControllerGCP)
+		t.Errorf("failed getImagesFromJSONFiles. Expected: %s, got: %s", expectedGCPImage, img.ClusterAPIControllerGCP)
 	}
 	if img.ClusterAPIControllerOvirt != expectedOvirtImage {
-		t.Errorf("failed getImagesFromJSONFile. Expected: %s, got: %s", expectedOvirtImage, img.ClusterAPIControllerOvirt)
+		t.Errorf("failed getImagesFromJSONFiles. Expected: %s, got: %s", expectedOvirtImage, img.ClusterAPIControllerOvirt)
 	}
 	if img.ClusterAPIControllerVSphere != expectedVSphereImage {
-		t.Errorf("failed getImagesFromJSONFile. Expected: %s, got: %s", expectedVSphereImage, img.ClusterAPIControllerVSphere)
+		t.Errorf("failed getImagesFromJSONFiles. Expected: %s, got: %s", expectedVSphereImage, img.ClusterAPIControllerVSphere)
 	}
 	if img.ClusterAPIControllerKubevirt != expectedKubevirtImage {
-		t.Errorf("failed getImagesFromJSONFile. Expected: %s, got: %s", expectedKubevirtImage, img.ClusterAPIControllerKubevirt)
+		t.Errorf("failed getImagesFromJSONFiles. Expected: %s, got: %s", expectedKubevirtImage, img.ClusterAPIControllerKubevirt)
+	}
+}
+
+func TestGetImagesFromJSONFilesMerge(t *testing.T) {
+	expectedOverrideAWSImage := "quay.io/openshift/origin-aws-machine-controllers:override"
+
+	img, err := getImagesFromJSONFiles([]string{imagesJSONFile, "fixtures/not-found.json", "fixtures/images-override.json"})
+	if err != nil {
+		t.Fatalf("failed getImagesFromJSONFiles: %v", err)
+	}
+	if img.ClusterAPIControllerAWS != expectedOverrideAWSImage {
+		t.Errorf("failed getImagesFromJSONFiles. Expected: %s, got: %s", expectedOverrideAWSImage, img.ClusterAPIControllerAWS)
+	}
+	if img.ClusterAPIControllerGCP != expectedGCPImage {
+		t.Errorf("failed getImagesFromJSONFiles. Expected: %s, got: %s", expectedGCPImage, img.ClusterAPIControllerGCP)
+	}
+
+	if _, err := getImagesFromJSONFiles([]string{"fixtures/not-found.json", imagesJSONFile}); !errors.Is(err, ErrImagesFileMissing) {
+		t.Errorf("expected ErrImagesFileMissing when the first images file is missing, got: %v", err)
+	}
+	if _, err := getImagesFromJSONFiles(nil); !errors.Is(err, ErrImagesFileMissing) {
+		t.Errorf("expected ErrImagesFileMissing when no images file is provided, got: %v", err)
+	}
+}
+
+func TestApplyImageDefaults(t *testing.T) {
+	img := Images{
+		ClusterAPIControllerAWS: expectedAWSImage,
+	}
+
+	defaulted := applyImageDefaults(&img)
+	if img.ClusterAPIControllerAWS != expectedAWSImage {
+		t.Errorf("failed applyImageDefaults. Expected: %s, got: %s", expectedAWSImage, img.ClusterAPIControllerAWS)
+	}
+	if img.KubeRBACProxy != defaultImages.KubeRBACProxy {
+		t.Errorf("failed applyImageDefaults. Expected: %s, got: %s", defaultImages.KubeRBACProxy, img.KubeRBACProxy)
+	}
+	if len(defaulted) != 10 {
+		t.Errorf("failed applyImageDefaults. Expected 10 defaulted fields, got: %v", defaulted)
+	}
+	for _, field := range defaulted {
+		if field == "clusterAPIControllerAWS" {
+			t.Errorf("failed applyImageDefaults. clusterAPIControllerAWS was set and must not be defaulted")
+		}
+	}
+
+	if defaulted := applyImageDefaults(&img); len(defaulted) != 0 {
+		t.Errorf("failed applyImageDefaults. Expected no defaulted fields, got: %v", defaulted)
+	}
+}
+
+func TestReadImagesFileRetries(t *testing.T) {
+	dir, err := ioutil.TempDir("", "images")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "images.json")
+	go func() {
+		time.Sleep(60 * time.Millisecond)
+		ioutil.WriteFile(path, []byte("{}"), 0600)
+	}()
+
+	// The file shows up while the read is being retried.
+	data, err := readImagesFile(path, false)
+	if err != nil {
+		t.Fatalf("failed readImagesFile: %v", err)
+	}
+	if string(data) != "{}" {
+		t.Errorf("failed readImagesFile. Expected: {}, got: %s", data)
+	}
+
+	// A missing optional file is not retried.
+	start := time.Now()
+	if _, err := readImagesFile(filepath.Join(dir, "missing.json"), true); !os.IsNotExist(err) {
+		t.Errorf("expected a not exist error, got: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 40*time.Millisecond {
+		t.Errorf("expected a missing optional file not to be retried, took %v", elapsed)
+	}
+}
+
+func TestGetImagesFromJSONFilesEnvOverrides(t *testing.T) {
+	expectedOverrideAWSImage := "quay.io/openshift/origin-aws-machine-controllers:env"
+	expectedOverrideKubeRBACProxyImage := "quay.io/openshift/origin-kube-rbac-proxy:env"
+
+	for env, value := range map[string]string{
+		"MAO_IMAGE_CLUSTER_API_CONTROLLER_AWS": expectedOverrideAWSImage,
+		"MAO_IMAGE_KUBE_RBAC_PROXY":            expectedOverrideKubeRBACProxyImage,
+	} {
+		os.Setenv(env, value)
+		defer os.Unsetenv(env)
+	}
+
+	img, err := getImagesFromJSONFiles([]string{imagesJSONFile, "fixtures/images-override.json"})
+	if err != nil {
+		t.Fatalf("failed getImagesFromJSONFiles: %v", err)
+	}
+	if img.ClusterAPIControllerAWS != expectedOverrideAWSImage {
+		t.Errorf("failed getImagesFromJSONFiles. Expected: %s, got: %s", expectedOverrideAWSImage, img.ClusterAPIControllerAWS)
+	}
+	if img.KubeRBACProxy != expectedOverrideKubeRBACProxyImage {
+		t.Errorf("failed getImagesFromJSONFiles. Expected: %s, got: %s", expectedOverrideKubeRBACProxyImage, img.KubeRBACProxy)
+	}
+	// Fields without an environment override keep the value from the files.
+	if img.ClusterAPIControllerGCP != expectedGCPImage {
+		t.Errorf("failed getImagesFromJSONFiles. Expected: %s, got: %s", expectedGCPImage, img.ClusterAPIControllerGCP)
+	}
+	if img.MachineAPIOperator != expectedMachineAPIOperatorImage {
+		t.Errorf("failed getImagesFromJSONFiles. Expected: %s, got: %s", expectedMachineAPIOperatorImage, img.MachineAPIOperator)
+	}
+}
+
+func TestImageEnvOverridesCoverAllImages(t *testing.T) {
+	var i Images
+	set := map[*string]string{}
+	for env, field := range imageEnvOverrides {
+		if other, ok := set[field(&i)]; ok {
+			t.Errorf("%s and %s override the same image", env, other)
+		}
+		set[field(&i)] = env
+	}
+	if n := reflect.TypeOf(i).NumField(); len(set) != n {
+		t.Errorf("expected an environment override for each of the %d images, got %d", n, len(set))
+	}
+}
+
+func TestGetImagesFromJSONFilesWrapsErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "images")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "images.json")
+	if err := ioutil.WriteFile(path, []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = getImagesFromJSONFiles([]string{path})
+	var syntaxErr *json.SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		t.Errorf("expected the JSON syntax error to be wrapped, got: %v", err)
 	}
 }
 
@@ -192,9 +389,9 @@ func TestGetProviderControllerFromImages(t *testing.T) {
 		},
 	}
 
-	img, err := getImagesFromJSONFile(imagesJSONFile)
+	img, err := getImagesFromJSONFiles([]string{imagesJSONFile})
 	if err != nil {
-		t.Errorf("failed getImagesFromJSONFile, %v", err)
+		t.Errorf("failed getImagesFromJSONFiles, %v", err)
 	}
 
 	for _, test := range tests {
@@ -208,6 +405,27 @@ func TestGetProviderControllerFromImages(t *testing.T) {
 	}
 }
 
+func TestGetProviderControllerFromImagesEmpty(t *testing.T) {
+	img, err := getImagesFromJSONFiles([]string{imagesJSONFile})
+	if err != nil {
+		t.Fatalf("failed getImagesFromJSONFiles, %v", err)
+	}
+	img.ClusterAPIControllerAzure = ""
+
+	if _, err := getProviderControllerFromImages(configv1.AzurePlatformType, *img); err == nil {
+		t.Errorf("expected an error for an empty Azure provider controller image")
+	}
+
+	// Unsupported platforms do not need an image.
+	res, err := getProviderControllerFromImages(configv1.NonePlatformType, Images{})
+	if err != nil {
+		t.Errorf("failed getProviderControllerFromImages: %v", err)
+	}
+	if res != clusterAPIControllerNoOp {
+		t.Errorf("failed getProviderControllerFromImages. Expected: %q, got: %q", clusterAPIControllerNoOp, res)
+	}
+}
+
 func TestGetTerminationHandlerFromImages(t *testing.T) {
 	tests := []struct {
 		provider      configv1.PlatformType
@@ -254,9 +472,9 @@ func TestGetTerminationHandlerFromImages(t *testing.T) {
 		},
 	}
 
-	img, err := getImagesFromJSONFile(imagesJSONFile)
+	img, err := getImagesFromJSONFiles([]string{imagesJSONFile})
 	if err != nil {
-		t.Errorf("failed getImagesFromJSONFile, %v", err)
+		t.Errorf("failed getImagesFromJSONFiles, %v", err)
 	}
 
 	for _, test := range tests {
@@ -271,9 +489,9 @@ func TestGetTerminationHandlerFromImages(t *testing.T) {
 }
 
 func TestGetMachineAPIOperatorFromImages(t *testing.T) {
-	img, err := getImagesFromJSONFile(imagesJSONFile)
+	img, err := getImagesFromJSONFiles([]string{imagesJSONFile})
 	if err != nil {
-		t.Errorf("failed getImagesFromJSONFile, %v", err)
+		t.Errorf("failed getImagesFromJSONFiles, %v", err)
 	}
 
 	res, err := getMachineAPIOperatorFromImages(*img)
@@ -286,9 +504,9 @@ func TestGetMachineAPIOperatorFromImages(t *testing.T) {
 }
 
 func TestGetKubeRBACProxyFromImages(t *testing.T) {
-	img, err := getImagesFromJSONFile(imagesJSONFile)
+	img, err := getImagesFromJSONFiles([]string{imagesJSONFile})
 	if err != nil {
-		t.Errorf("failed getImagesFromJSONFile, %v", err)
+		t.Errorf("failed getImagesFromJSONFiles, %v", err)
 	}
 
 	res, err := getKubeRBACProxyFromImages(*img)
@@ -299,3 +517,28 @@ func TestGetKubeRBACProxyFromImages(t *testing.T) {
 		t.Errorf("failed getKubeRBACProxyFromImages. Expected: %s, got: %s", expectedKubeRBACProxyImage, res)
 	}
 }
+
+func TestValidateImageDigests(t *testing.T) {
+	digest := "@sha256:" + strings.Repeat("a", 64)
+	pinned := Controllers{
+		Provider:           "quay.io/openshift/origin-aws-machine-controllers" + digest,
+		MachineSet:         "quay.io/openshift/origin-machine-api-operator" + digest,
+		NodeLink:           "quay.io/openshift/origin-machine-api-operator" + digest,
+		MachineHealthCheck: "quay.io/openshift/origin-machine-api-operator" + digest,
+		KubeRBACProxy:      "quay.io/openshift/origin-kube-rbac-proxy" + digest,
+		TerminationHandler: clusterAPIControllerNoOp,
+	}
+	if err := validateImageDigests(pinned); err != nil {
+		t.Errorf("expected pinned images to be valid, got: %v", err)
+	}
+
+	tagged := pinned
+	tagged.KubeRBACProxy = expectedKubeRBACProxyImage
+	err := validateImageDigests(tagged)
+	if err == nil || !strings.Contains(err.Error(), expectedKubeRBACProxyImage) {
+		t.Errorf("expected an error naming %s, got: %v", expectedKubeRBACProxyImage, err)
+	}
+	if !isPermanentError(err) {
+		t.Errorf("expected a permanent error, got: %v", err)
+	}
+}
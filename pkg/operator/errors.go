@@ -0,0 +1,66 @@
+package operator
+
+import (
+	"errors"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+// Sentinel errors wrapped by the errors returned while building the operator
+// config, so that callers can tell them apart with errors.Is.
+var (
+	// ErrConfigNotFound means a cluster config object the operator reads,
+	// such as the Infrastructure or the Proxy, doesn't exist.
+	ErrConfigNotFound = errors.New("cluster config not found")
+	// ErrInvalidProvider means the platform of the cluster can't be determined.
+	ErrInvalidProvider = errors.New("invalid platform provider")
+	// ErrImagesFileMissing means the required images file can't be found.
+	ErrImagesFileMissing = errors.New("images file missing")
+	// ErrNamespaceNotAllowed means an operand would be written into a namespace
+	// outside of the allowed namespaces of the config.
+	ErrNamespaceNotAllowed = errors.New("namespace not allowed")
+)
+
+// PermanentError wraps a sync error that retrying the sync can't fix, such as
+// an invalid configuration. It is reported as Degraded right away instead of
+// being retried with backoff.
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// NewPermanentError marks err as permanent.
+func NewPermanentError(err error) error {
+	return &PermanentError{Err: err}
+}
+
+// isPermanentError reports whether err won't go away by retrying the sync.
+// An aggregate is only permanent if all of its errors are.
+func isPermanentError(err error) bool {
+	var agg utilerrors.Aggregate
+	if errors.As(err, &agg) {
+		for _, e := range agg.Errors() {
+			if !isPermanentError(e) {
+				return false
+			}
+		}
+		return len(agg.Errors()) > 0
+	}
+
+	var permanent *PermanentError
+	if errors.As(err, &permanent) {
+		return true
+	}
+	return apierrors.IsForbidden(err) ||
+		apierrors.IsInvalid(err) ||
+		apierrors.IsBadRequest(err) ||
+		apierrors.IsMethodNotSupported(err)
+}
@@ -0,0 +1,92 @@
+package operator
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func TestIsPermanentError(t *testing.T) {
+	gr := schema.GroupResource{Group: "apps", Resource: "deployments"}
+	permanent := NewPermanentError(errors.New("invalid config"))
+
+	testCases := []struct {
+		name      string
+		err       error
+		permanent bool
+	}{
+		{
+			name:      "permanent error",
+			err:       permanent,
+			permanent: true,
+		},
+		{
+			name:      "wrapped permanent error",
+			err:       fmt.Errorf("syncing: %w", permanent),
+			permanent: true,
+		},
+		{
+			name:      "forbidden",
+			err:       apierrors.NewForbidden(gr, "machine-api-controllers", errors.New("denied")),
+			permanent: true,
+		},
+		{
+			name:      "invalid",
+			err:       apierrors.NewInvalid(schema.GroupKind{Group: "apps", Kind: "Deployment"}, "machine-api-controllers", field.ErrorList{}),
+			permanent: true,
+		},
+		{
+			name:      "bad request",
+			err:       apierrors.NewBadRequest("bad request"),
+			permanent: true,
+		},
+		{
+			name:      "not found",
+			err:       apierrors.NewNotFound(gr, "machine-api-controllers"),
+			permanent: false,
+		},
+		{
+			name:      "conflict",
+			err:       apierrors.NewConflict(gr, "machine-api-controllers", errors.New("conflict")),
+			permanent: false,
+		},
+		{
+			name:      "server timeout",
+			err:       apierrors.NewServerTimeout(gr, "get", 1),
+			permanent: false,
+		},
+		{
+			name:      "generic error",
+			err:       errors.New("deployment machine-api-controllers is not ready"),
+			permanent: false,
+		},
+		{
+			name:      "aggregate of permanent errors",
+			err:       utilerrors.NewAggregate([]error{permanent, apierrors.NewBadRequest("bad request")}),
+			permanent: true,
+		},
+		{
+			name:      "wrapped aggregate of permanent errors",
+			err:       fmt.Errorf("webhooks: %w", utilerrors.NewAggregate([]error{permanent})),
+			permanent: true,
+		},
+		{
+			name:      "aggregate with a transient error",
+			err:       utilerrors.NewAggregate([]error{permanent, errors.New("rollout timed out")}),
+			permanent: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := isPermanentError(tc.err); got != tc.permanent {
+				t.Errorf("isPermanentError(%v) = %v, expected %v", tc.err, got, tc.permanent)
+			}
+		})
+	}
+}
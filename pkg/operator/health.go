@@ -0,0 +1,113 @@
+package operator
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// serverShutdownTimeout bounds how long in-flight requests are given to finish
+// once a server is stopped.
+const serverShutdownTimeout = 5 * time.Second
+
+// Health tracks what the health and readiness endpoints report. It is shared
+// with the caller serving the endpoints, see ServeHealth, so replicas waiting
+// for the leader lease serve them too. The zero value is ready to use.
+type Health struct {
+	lock         sync.RWMutex
+	leading      bool
+	cachesSynced bool
+	stopped      bool
+}
+
+func (h *Health) setLeading() {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.leading = true
+}
+
+func (h *Health) setCachesSynced() {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.cachesSynced = true
+}
+
+func (h *Health) setStopped() {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.stopped = true
+}
+
+// live reports whether the operator still runs, or waits for the leader lease.
+func (h *Health) live() bool {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	return !h.stopped
+}
+
+// ready reports whether the operator either waits for the leader lease or has
+// synced its informer caches. Failing syncs are reported on the ClusterOperator
+// rather than here, so they don't make the pod unready.
+func (h *Health) ready() bool {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	return !h.leading || h.cachesSynced
+}
+
+// Handler serves /healthz, which reflects live, and /readyz, which reflects ready.
+func (h *Health) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", probeHandler(h.live))
+	mux.HandleFunc("/readyz", probeHandler(h.ready))
+	return mux
+}
+
+func probeHandler(check func() bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		if !check() {
+			http.Error(w, "not ready", http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok"))
+	}
+}
+
+// ServeHealth serves the health and readiness endpoints of h on addr until stopCh is closed.
+func ServeHealth(addr string, h *Health, stopCh <-chan struct{}) error {
+	klog.Infof("Serving health and readiness endpoints on %s", addr)
+	return ServeUntilStopped(&http.Server{Addr: addr, Handler: h.Handler()}, stopCh)
+}
+
+// ServeUntilStopped serves server until stopCh is closed, then shuts it down
+// gracefully, giving in-flight requests serverShutdownTimeout to finish. The
+// listener is closed once it returns, so the address can be bound again right
+// away, e.g. by a restarted operator.
+func ServeUntilStopped(server *http.Server, stopCh <-chan struct{}) error {
+	listener, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		return err
+	}
+
+	shutdownDone := make(chan struct{})
+	go func() {
+		defer close(shutdownDone)
+		<-stopCh
+		ctx, cancel := context.WithTimeout(context.Background(), serverShutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			klog.Errorf("Error shutting down server on %s: %v", server.Addr, err)
+			server.Close()
+		}
+	}()
+
+	if err := server.Serve(listener); err != http.ErrServerClosed {
+		return err
+	}
+	<-shutdownDone
+	return nil
+}
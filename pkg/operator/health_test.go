@@ -0,0 +1,86 @@
+package operator
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestHealthHandler(t *testing.T) {
+	g := NewWithT(t)
+
+	stopCh := make(<-chan struct{})
+	optr := newFakeOperator(nil, nil, stopCh)
+	handler := optr.health.Handler()
+
+	probe := func(path string) int {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec.Code
+	}
+
+	// Replicas waiting for the leader lease are ready.
+	g.Expect(probe("/healthz")).To(Equal(http.StatusOK))
+	g.Expect(probe("/readyz")).To(Equal(http.StatusOK))
+
+	// The leader is not ready before its caches are synced.
+	optr.health.setLeading()
+	g.Expect(probe("/healthz")).To(Equal(http.StatusOK))
+	g.Expect(probe("/readyz")).To(Equal(http.StatusServiceUnavailable))
+
+	optr.health.setCachesSynced()
+	g.Expect(probe("/healthz")).To(Equal(http.StatusOK))
+	g.Expect(probe("/readyz")).To(Equal(http.StatusOK))
+
+	// A failing sync is reported on the ClusterOperator, the pod stays ready.
+	optr.handleErr(context.Background(), errors.New("sync failed"), "trigger")
+	g.Expect(probe("/healthz")).To(Equal(http.StatusOK))
+	g.Expect(probe("/readyz")).To(Equal(http.StatusOK))
+
+	// Once Run returned, the pod is restarted.
+	optr.health.setStopped()
+	g.Expect(probe("/healthz")).To(Equal(http.StatusServiceUnavailable))
+}
+
+func TestServeUntilStopped(t *testing.T) {
+	g := NewWithT(t)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	g.Expect(err).ToNot(HaveOccurred())
+	addr := listener.Addr().String()
+	g.Expect(listener.Close()).To(Succeed())
+
+	stopCh := make(chan struct{})
+	served := make(chan error)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) })
+	go func() {
+		served <- ServeUntilStopped(&http.Server{Addr: addr, Handler: handler}, stopCh)
+	}()
+
+	g.Eventually(func() error {
+		resp, err := http.Get("http://" + addr)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}).Should(Succeed())
+
+	close(stopCh)
+	g.Eventually(served).Should(Receive(BeNil()))
+
+	// The port is free again once the server returned.
+	listener, err = net.Listen("tcp", addr)
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(listener.Close()).To(Succeed())
+
+	// A port in use is reported rather than retried.
+	listener, err = net.Listen("tcp", "127.0.0.1:0")
+	g.Expect(err).ToNot(HaveOccurred())
+	defer listener.Close()
+	g.Expect(ServeUntilStopped(&http.Server{Addr: listener.Addr().String(), Handler: handler}, stopCh)).ToNot(Succeed())
+}
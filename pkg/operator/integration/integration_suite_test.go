@@ -0,0 +1,59 @@
+package integration
+
+import (
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"k8s.io/client-go/rest"
+	"k8s.io/klog/v2"
+	"k8s.io/klog/v2/klogr"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+	"sigs.k8s.io/controller-runtime/pkg/envtest/printer"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func init() {
+	klog.InitFlags(nil)
+	logf.SetLogger(klogr.New())
+}
+
+var (
+	cfg     *rest.Config
+	testEnv *envtest.Environment
+)
+
+func TestOperatorIntegration(t *testing.T) {
+	RegisterFailHandler(Fail)
+
+	RunSpecsWithDefaultAndCustomReporters(t,
+		"Operator Integration Suite",
+		[]Reporter{printer.NewlineReporter{}})
+}
+
+var _ = BeforeSuite(func(done Done) {
+	By("bootstrapping test environment")
+	configCRDs := filepath.Join("..", "..", "..", "vendor", "github.com", "openshift", "api", "config", "v1")
+	testEnv = &envtest.Environment{
+		CRDDirectoryPaths: []string{
+			filepath.Join(configCRDs, "0000_00_cluster-version-operator_01_clusteroperator.crd.yaml"),
+			filepath.Join(configCRDs, "0000_03_config-operator_01_proxy.crd.yaml"),
+			filepath.Join(configCRDs, "0000_10_config-operator_01_featuregate.crd.yaml"),
+			filepath.Join(configCRDs, "0000_10_config-operator_01_infrastructure.crd.yaml"),
+		},
+		ErrorIfCRDPathMissing: true,
+	}
+
+	var err error
+	cfg, err = testEnv.Start()
+	Expect(err).ToNot(HaveOccurred())
+	Expect(cfg).ToNot(BeNil())
+
+	close(done)
+}, 60)
+
+var _ = AfterSuite(func() {
+	By("tearing down the test environment")
+	Expect(testEnv.Stop()).To(Succeed())
+})
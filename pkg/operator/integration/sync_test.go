@@ -0,0 +1,120 @@
+package integration
+
+import (
+	"context"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	configv1 "github.com/openshift/api/config/v1"
+	osclientset "github.com/openshift/client-go/config/clientset/versioned"
+	configinformersv1 "github.com/openshift/client-go/config/informers/externalversions"
+	"github.com/openshift/machine-api-operator/pkg/operator"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/record"
+)
+
+const (
+	operatorNamespace = "openshift-machine-api"
+	operatorName      = "machine-api"
+	timeout           = 30 * time.Second
+)
+
+var _ = Describe("Operator sync", func() {
+	var (
+		ctx        = context.Background()
+		stopCh     chan struct{}
+		kubeClient kubernetes.Interface
+		osClient   osclientset.Interface
+	)
+
+	BeforeEach(func() {
+		stopCh = make(chan struct{})
+		kubeClient = kubernetes.NewForConfigOrDie(cfg)
+		osClient = osclientset.NewForConfigOrDie(cfg)
+
+		By("seeding the cluster configuration for the libvirt platform")
+		_, err := kubeClient.CoreV1().Namespaces().Create(ctx, &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{Name: operatorNamespace},
+		}, metav1.CreateOptions{})
+		Expect(err).ToNot(HaveOccurred())
+
+		infra, err := osClient.ConfigV1().Infrastructures().Create(ctx, &configv1.Infrastructure{
+			ObjectMeta: metav1.ObjectMeta{Name: "cluster"},
+		}, metav1.CreateOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		infra.Status.Platform = configv1.LibvirtPlatformType
+		_, err = osClient.ConfigV1().Infrastructures().UpdateStatus(ctx, infra, metav1.UpdateOptions{})
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = osClient.ConfigV1().Proxies().Create(ctx, &configv1.Proxy{
+			ObjectMeta: metav1.ObjectMeta{Name: "cluster"},
+		}, metav1.CreateOptions{})
+		Expect(err).ToNot(HaveOccurred())
+
+		By("starting the operator")
+		kubeInformers := informers.NewSharedInformerFactoryWithOptions(kubeClient, 0, informers.WithNamespace(operatorNamespace))
+		configInformers := configinformersv1.NewSharedInformerFactory(osClient, 0)
+		options := operator.DefaultOptions()
+		// Nothing rolls the operands out in envtest, so don't wait long for it.
+		options.RolloutPollInterval = 100 * time.Millisecond
+		options.RolloutTimeout = time.Second
+		optr := operator.New(
+			operatorNamespace, operatorName,
+			[]string{filepath.Join("..", "fixtures", "images.json")},
+			"",
+			options,
+			kubeInformers.Apps().V1().Deployments(),
+			kubeInformers.Apps().V1().DaemonSets(),
+			configInformers.Config().V1().FeatureGates(),
+			kubeInformers.Admissionregistration().V1().ValidatingWebhookConfigurations(),
+			kubeInformers.Admissionregistration().V1().MutatingWebhookConfigurations(),
+			configInformers.Config().V1().Proxies(),
+			configInformers.Config().V1().Infrastructures(),
+			configInformers.Config().V1().ClusterOperators(),
+			kubeInformers.Core().V1().Secrets(),
+			kubeInformers.Core().V1().ConfigMaps(),
+			kubeClient,
+			osClient,
+			dynamic.NewForConfigOrDie(cfg),
+			record.NewFakeRecorder(100),
+		)
+		kubeInformers.Start(stopCh)
+		configInformers.Start(stopCh)
+		go optr.Run(1, stopCh)
+	})
+
+	AfterEach(func() {
+		close(stopCh)
+	})
+
+	It("applies the operands and reports the ClusterOperator status", func() {
+		Eventually(func() error {
+			_, err := kubeClient.AdmissionregistrationV1().ValidatingWebhookConfigurations().Get(ctx, "machine-api", metav1.GetOptions{})
+			return err
+		}, timeout).Should(Succeed())
+
+		Eventually(func() error {
+			_, err := kubeClient.AdmissionregistrationV1().MutatingWebhookConfigurations().Get(ctx, "machine-api", metav1.GetOptions{})
+			return err
+		}, timeout).Should(Succeed())
+
+		Eventually(func() error {
+			_, err := kubeClient.AppsV1().Deployments(operatorNamespace).Get(ctx, "machine-api-controllers", metav1.GetOptions{})
+			return err
+		}, timeout).Should(Succeed())
+
+		Eventually(func() []configv1.ClusterOperatorStatusCondition {
+			co, err := osClient.ConfigV1().ClusterOperators().Get(ctx, operatorName, metav1.GetOptions{})
+			if err != nil {
+				return nil
+			}
+			return co.Status.Conditions
+		}, timeout).ShouldNot(BeEmpty())
+	})
+})
@@ -0,0 +1,97 @@
+package operator
+
+import (
+	"context"
+	"fmt"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/diff"
+	"k8s.io/klog/v2"
+)
+
+// observeDrift compares every object the operator would apply for the given
+// config with its live counterpart and reports the ones which drifted from it
+// in the Progressing condition, without writing any of them. An event is
+// recorded when an object starts drifting, not on every sync it stays drifted.
+func (optr *Operator) observeDrift(ctx context.Context, config *OperatorConfig) error {
+	var drifted []string
+	driftedMessages := map[string]string{}
+	reportDrift := func(name, message string) {
+		if optr.drifted[name] != message {
+			optr.eventf(ctx, corev1.EventTypeWarning, "DriftDetected", "%s", message)
+		}
+		driftedMessages[name] = message
+		drifted = append(drifted, name)
+	}
+	if config.Controllers.Provider != clusterAPIControllerNoOp {
+		controllersDeployment, err := optr.newControllersDeployment(config)
+		if err != nil {
+			return err
+		}
+
+		for _, required := range renderManifests(config, controllersDeployment, optr.options.ControllersMinAvailable) {
+			accessor, err := meta.Accessor(required)
+			if err != nil {
+				return err
+			}
+			setManagedByLabel(accessor)
+			name := fmt.Sprintf("%s %s", manifestKind(required), accessor.GetName())
+			if accessor.GetNamespace() != "" {
+				name = fmt.Sprintf("%s %s/%s", manifestKind(required), accessor.GetNamespace(), accessor.GetName())
+			}
+
+			live, err := optr.getLiveObject(ctx, required)
+			if apierrors.IsNotFound(err) {
+				reportDrift(name, fmt.Sprintf("%s does not exist", name))
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if hasDrifted(required, live) {
+				klog.V(2).Infof("%s drifted from the desired state: %s", name, diff.ObjectReflectDiff(required, live))
+				reportDrift(name, fmt.Sprintf("%s drifted from the desired state", name))
+			}
+		}
+	}
+	optr.drifted = driftedMessages
+	return optr.statusObserving(ctx, drifted)
+}
+
+// hasDrifted returns whether live differs from required in any of the fields
+// the operator renders. Fields left unset in required, e.g. the ones defaulted
+// by the API server, are ignored.
+func hasDrifted(required, live runtime.Object) bool {
+	// Rendered objects may carry type meta, which objects read through the typed
+	// clients don't.
+	required = required.DeepCopyObject()
+	required.GetObjectKind().SetGroupVersionKind(schema.GroupVersionKind{})
+	return !equality.Semantic.DeepDerivative(required, live)
+}
+
+// getLiveObject reads the live counterpart of a rendered object.
+func (optr *Operator) getLiveObject(ctx context.Context, required runtime.Object) (runtime.Object, error) {
+	switch obj := required.(type) {
+	case *admissionregistrationv1.ValidatingWebhookConfiguration:
+		return optr.kubeClient.AdmissionregistrationV1().ValidatingWebhookConfigurations().Get(ctx, obj.Name, metav1.GetOptions{})
+	case *admissionregistrationv1.MutatingWebhookConfiguration:
+		return optr.kubeClient.AdmissionregistrationV1().MutatingWebhookConfigurations().Get(ctx, obj.Name, metav1.GetOptions{})
+	case *appsv1.Deployment:
+		return optr.kubeClient.AppsV1().Deployments(obj.Namespace).Get(ctx, obj.Name, metav1.GetOptions{})
+	case *appsv1.DaemonSet:
+		return optr.kubeClient.AppsV1().DaemonSets(obj.Namespace).Get(ctx, obj.Name, metav1.GetOptions{})
+	case *policyv1beta1.PodDisruptionBudget:
+		return optr.kubeClient.PolicyV1beta1().PodDisruptionBudgets(obj.Namespace).Get(ctx, obj.Name, metav1.GetOptions{})
+	default:
+		return nil, fmt.Errorf("unsupported object %T", required)
+	}
+}
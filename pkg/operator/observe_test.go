@@ -0,0 +1,159 @@
+package operator
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	configv1 "github.com/openshift/api/config/v1"
+	"github.com/openshift/library-go/pkg/config/clusteroperator/v1helpers"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakekube "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/pointer"
+)
+
+func TestObserveDrift(t *testing.T) {
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	optr := newFakeOperator(nil, nil, stopCh)
+	optr.options.Observe = true
+	// The informers list and watch once started, wait for it so that only the
+	// requests of the syncs are recorded.
+	if !cache.WaitForCacheSync(stopCh, optr.deployListerSynced, optr.daemonsetListerSynced, optr.mutatingWebhookListerSynced,
+		optr.validatingWebhookListerSynced, optr.secretListerSynced) {
+		t.Fatal("failed to sync caches")
+	}
+	config := &OperatorConfig{
+		TargetNamespace: targetNamespace,
+		Controllers: Controllers{
+			Provider:           "provider-image",
+			TerminationHandler: "provider-image",
+		},
+	}
+	ctx := context.Background()
+	kubeClient := optr.kubeClient.(*fakekube.Clientset)
+
+	getProgressingMessage := func() string {
+		co, err := optr.getClusterOperator(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		progressing := v1helpers.FindStatusCondition(co.Status.Conditions, configv1.OperatorProgressing)
+		if progressing == nil || progressing.Reason != string(ReasonObserving) {
+			t.Fatalf("expected Progressing with the %s reason, got: %v", ReasonObserving, progressing)
+		}
+		return progressing.Message
+	}
+	recorder := optr.eventRecorder.(*record.FakeRecorder)
+	countEvents := func() int {
+		n := 0
+		for {
+			select {
+			case <-recorder.Events:
+				n++
+			default:
+				return n
+			}
+		}
+	}
+
+	// Nothing exists yet, so every operand drifted, but none is created.
+	if err := optr.syncAll(ctx, config); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, action := range kubeClient.Actions() {
+		if action.GetVerb() != "get" && action.GetVerb() != "list" && action.GetVerb() != "watch" {
+			t.Errorf("expected no operand to be written in observe mode, got: %v", action)
+		}
+	}
+	message := getProgressingMessage()
+	for _, name := range []string{
+		"ValidatingWebhookConfiguration machine-api",
+		"MutatingWebhookConfiguration machine-api",
+		"Deployment " + targetNamespace + "/machine-api-controllers",
+		"PodDisruptionBudget " + targetNamespace + "/machine-api-controllers",
+		"DaemonSet " + targetNamespace + "/" + machineAPITerminationHandler,
+	} {
+		if !strings.Contains(message, name) {
+			t.Errorf("expected %s to be reported as drifted, got: %s", name, message)
+		}
+	}
+	if n := countEvents(); n != 5 {
+		t.Errorf("expected an event per drifted operand, got %d", n)
+	}
+
+	// Operands staying drifted are not reported in events again.
+	if err := optr.syncAll(ctx, config); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n := countEvents(); n != 0 {
+		t.Errorf("expected no event for operands still drifted, got %d", n)
+	}
+
+	// Create the operands as rendered, with fields defaulted by the API server.
+	deployment, err := optr.newControllersDeployment(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, obj := range renderManifests(config, deployment, optr.options.ControllersMinAvailable) {
+		obj = obj.DeepCopyObject()
+		switch obj := obj.(type) {
+		case *admissionregistrationv1.ValidatingWebhookConfiguration:
+			setManagedByLabel(obj)
+			_, err = kubeClient.AdmissionregistrationV1().ValidatingWebhookConfigurations().Create(ctx, obj, metav1.CreateOptions{})
+		case *admissionregistrationv1.MutatingWebhookConfiguration:
+			setManagedByLabel(obj)
+			_, err = kubeClient.AdmissionregistrationV1().MutatingWebhookConfigurations().Create(ctx, obj, metav1.CreateOptions{})
+		case *appsv1.Deployment:
+			setManagedByLabel(obj)
+			obj.Spec.RevisionHistoryLimit = pointer.Int32Ptr(10)
+			_, err = kubeClient.AppsV1().Deployments(obj.Namespace).Create(ctx, obj, metav1.CreateOptions{})
+		case *appsv1.DaemonSet:
+			setManagedByLabel(obj)
+			_, err = kubeClient.AppsV1().DaemonSets(obj.Namespace).Create(ctx, obj, metav1.CreateOptions{})
+		case *policyv1beta1.PodDisruptionBudget:
+			setManagedByLabel(obj)
+			_, err = kubeClient.PolicyV1beta1().PodDisruptionBudgets(obj.Namespace).Create(ctx, obj, metav1.CreateOptions{})
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := optr.syncAll(ctx, config); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if message := getProgressingMessage(); strings.Contains(message, "drifted from the desired state:") {
+		t.Errorf("expected no drift, got: %s", message)
+	}
+
+	// Someone scales the controllers up.
+	live, err := kubeClient.AppsV1().Deployments(targetNamespace).Get(ctx, "machine-api-controllers", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	live.Spec.Replicas = pointer.Int32Ptr(3)
+	if _, err := kubeClient.AppsV1().Deployments(targetNamespace).Update(ctx, live, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	kubeClient.ClearActions()
+	if err := optr.syncAll(ctx, config); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	message = getProgressingMessage()
+	if !strings.Contains(message, "Deployment "+targetNamespace+"/machine-api-controllers") || strings.Contains(message, "DaemonSet") {
+		t.Errorf("expected only the Deployment to be reported as drifted, got: %s", message)
+	}
+	if n := countEvents(); n != 1 {
+		t.Errorf("expected an event for the Deployment drifting, got %d", n)
+	}
+	for _, action := range kubeClient.Actions() {
+		if action.GetVerb() != "get" {
+			t.Errorf("expected no operand to be written in observe mode, got: %v", action)
+		}
+	}
+}
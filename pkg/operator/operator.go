@@ -4,23 +4,34 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/fsnotify/fsnotify"
 	osconfigv1 "github.com/openshift/api/config/v1"
 	osoperatorv1 "github.com/openshift/api/operator/v1"
 	osclientset "github.com/openshift/client-go/config/clientset/versioned"
 	configinformersv1 "github.com/openshift/client-go/config/informers/externalversions/config/v1"
 	configlistersv1 "github.com/openshift/client-go/config/listers/config/v1"
+	"github.com/openshift/machine-api-operator/pkg/metrics"
+	"golang.org/x/time/rate"
 	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/dynamic"
 	admissioninformersv1 "k8s.io/client-go/informers/admissionregistration/v1"
 	appsinformersv1 "k8s.io/client-go/informers/apps/v1"
+	coreinformersv1 "k8s.io/client-go/informers/core/v1"
 	"k8s.io/client-go/kubernetes"
 	admissionlisterv1 "k8s.io/client-go/listers/admissionregistration/v1"
 	appslisterv1 "k8s.io/client-go/listers/apps/v1"
+	corelisterv1 "k8s.io/client-go/listers/core/v1"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/workqueue"
@@ -28,28 +39,83 @@ import (
 )
 
 const (
-	// maxRetries is the number of times a key will be retried before it is dropped out of the queue.
-	// With the current rate-limiter in use (5ms*2^(maxRetries-1)) the following numbers represent the times
+	// DefaultMaxRetries is the default number of times a key will be retried before it is dropped out of the queue.
+	// With the default rate-limiter (5ms*2^(maxRetries-1)) the following numbers represent the times
 	// a machineconfig pool is going to be requeued:
 	//
 	// 5ms, 10ms, 20ms, 40ms, 80ms, 160ms, 320ms, 640ms, 1.3s, 2.6s, 5.1s, 10.2s, 20.4s, 41s, 82s
-	maxRetries         = 15
+	DefaultMaxRetries = 15
+	// DefaultResyncInterval is the default interval at which a full sync is enqueued regardless of watch events.
+	DefaultResyncInterval = 5 * time.Minute
+	// DefaultSyncDebounce is the default window in which enqueued syncs are coalesced.
+	DefaultSyncDebounce = time.Second
+	// DefaultBaseBackoff is the default delay before the first retry of a failed sync.
+	DefaultBaseBackoff = 5 * time.Millisecond
+	// DefaultMaxBackoff is the default upper bound on the delay between retries of a failed sync.
+	DefaultMaxBackoff = 1000 * time.Second
+	// DefaultRolloutPollInterval is the default interval at which an operand rollout is checked.
+	DefaultRolloutPollInterval = time.Second
+	// DefaultRolloutTimeout is the default time an operand is given to roll out before the sync fails.
+	DefaultRolloutTimeout = 5 * time.Minute
+	// DefaultControllersMinAvailable derives the minAvailable of the controllers
+	// PodDisruptionBudget from the replicas of their Deployment.
+	DefaultControllersMinAvailable = -1
+	// DefaultControllerReplicas is the default number of replicas of the machine-api-controllers Deployment.
+	DefaultControllerReplicas = 1
+
 	maoOwnedAnnotation = "machine.openshift.io/owned"
+	// configHashAnnotation holds the hash of the rendered object an operand was
+	// last applied from, so unchanged operands are not applied again.
+	configHashAnnotation = "machine.openshift.io/config-hash"
+
+	// pausedAnnotation on the machine-api ClusterOperator stops the operator
+	// from reconciling any of its operands while it is present.
+	pausedAnnotation = "machine.openshift.io/paused"
+	// forceSyncAnnotation on the machine-api ClusterOperator triggers an immediate
+	// full sync whenever its value changes. Any nonce can be used as the value.
+	forceSyncAnnotation = "machine.openshift.io/force-sync"
+
+	// managedByLabel is set on every object the operator applies.
+	managedByLabel      = "app.kubernetes.io/managed-by"
+	managedByLabelValue = "machine-api-operator"
+
+	// syncAPICallsTimeout is the part of the sync timeout left for the API calls around the operand rollouts.
+	syncAPICallsTimeout = 5 * time.Minute
+
+	// cacheSyncTimeout is how long Run waits for the informer caches to sync
+	// before reporting an informer that doesn't, e.g. for lack of RBAC, as
+	// Degraded. Run keeps waiting for it afterwards.
+	cacheSyncTimeout = 5 * time.Minute
+	// startupJitter bounds the random delay before each worker starts.
+	startupJitter = 500 * time.Millisecond
+
+	// DefaultDrainTimeout is the default time Run waits for in-flight syncs to finish on shutdown.
+	DefaultDrainTimeout = 30 * time.Second
 )
 
 // Operator defines machine api operator.
 type Operator struct {
 	namespace, name string
 
-	imagesFile string
-	config     string
+	imagesFiles []string
+	config      string
+	options     Options
+	health      *Health
+	// startupJitter bounds the random delay before the workers start.
+	startupJitter time.Duration
+	// cacheSyncTimeout is how long Run waits for the informer caches to sync before reporting it.
+	cacheSyncTimeout time.Duration
 
 	kubeClient    kubernetes.Interface
 	osClient      osclientset.Interface
 	dynamicClient dynamic.Interface
 	eventRecorder record.EventRecorder
 
-	syncHandler func(ic string) error
+	syncHandler func(ctx context.Context, key workKey) error
+	// syncLock serializes the syncs run by the workers.
+	syncLock sync.Mutex
+	// reconcilers are the steps of a full sync, run in order.
+	reconcilers []Reconciler
 
 	deployLister       appslisterv1.DeploymentLister
 	deployListerSynced cache.InformerSynced
@@ -60,6 +126,8 @@ type Operator struct {
 	proxyLister       configlistersv1.ProxyLister
 	proxyListerSynced cache.InformerSynced
 
+	infrastructureListerSynced cache.InformerSynced
+
 	validatingWebhookLister       admissionlisterv1.ValidatingWebhookConfigurationLister
 	validatingWebhookListerSynced cache.InformerSynced
 
@@ -69,19 +137,32 @@ type Operator struct {
 	featureGateLister      configlistersv1.FeatureGateLister
 	featureGateCacheSynced cache.InformerSynced
 
+	secretLister       corelisterv1.SecretLister
+	secretListerSynced cache.InformerSynced
+
 	// queue only ever has one item, but it has nice error handling backoff/retry semantics
 	queue           workqueue.RateLimitingInterface
 	operandVersions []osconfigv1.OperandVersion
 
-	generations []osoperatorv1.GenerationStatus
+	// generationsLock guards generations, which operands syncing concurrently update.
+	generationsLock sync.Mutex
+	generations     []osoperatorv1.GenerationStatus
+
+	// defaultedImages lists the images last defaulted for missing from the images files.
+	defaultedImages string
+
+	// drifted maps the objects found drifted by the last observe sync to how
+	// they drifted, so that an event is only recorded when that changes.
+	drifted map[string]string
 }
 
 // New returns a new machine config operator.
 func New(
 	namespace, name string,
-	imagesFile string,
+	imagesFiles []string,
 
 	config string,
+	options Options,
 
 	deployInformer appsinformersv1.DeploymentInformer,
 	daemonsetInformer appsinformersv1.DaemonSetInformer,
@@ -89,6 +170,10 @@ func New(
 	validatingWebhookInformer admissioninformersv1.ValidatingWebhookConfigurationInformer,
 	mutatingWebhookInformer admissioninformersv1.MutatingWebhookConfigurationInformer,
 	proxyInformer configinformersv1.ProxyInformer,
+	infrastructureInformer configinformersv1.InfrastructureInformer,
+	clusterOperatorInformer configinformersv1.ClusterOperatorInformer,
+	secretInformer coreinformersv1.SecretInformer,
+	configMapInformer coreinformersv1.ConfigMapInformer,
 	kubeClient kubernetes.Interface,
 	osClient osclientset.Interface,
 	dynamicClient dynamic.Interface,
@@ -105,23 +190,41 @@ func New(
 	optr := &Operator{
 		namespace:       namespace,
 		name:            name,
-		imagesFile:      imagesFile,
+		imagesFiles:     imagesFiles,
 		kubeClient:      kubeClient,
 		osClient:        osClient,
 		dynamicClient:   dynamicClient,
 		eventRecorder:   recorder,
-		queue:           workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "machineapioperator"),
+		queue:           workqueue.NewNamedRateLimitingQueue(newRateLimiter(options.BaseBackoff, options.MaxBackoff), "machineapioperator"),
 		operandVersions: operandVersions,
 	}
 
-	deployInformer.Informer().AddEventHandler(optr.eventHandlerDeployments())
-	daemonsetInformer.Informer().AddEventHandler(optr.eventHandler())
-	validatingWebhookInformer.Informer().AddEventHandler(optr.eventHandlerSingleton(isMachineWebhook))
-	mutatingWebhookInformer.Informer().AddEventHandler(optr.eventHandlerSingleton(isMachineWebhook))
-	featureGateInformer.Informer().AddEventHandler(optr.eventHandler())
+	deployInformer.Informer().AddEventHandler(optr.eventHandler(isManagedByOperator))
+	daemonsetInformer.Informer().AddEventHandler(optr.eventHandler(isManagedByOperator))
+	validatingWebhookInformer.Informer().AddEventHandler(optr.eventHandlerSingleton(isMachineWebhook, syncScopeWebhooks))
+	mutatingWebhookInformer.Informer().AddEventHandler(optr.eventHandlerSingleton(isMachineWebhook, syncScopeWebhooks))
+	featureGateInformer.Informer().AddEventHandler(optr.eventHandler(isClusterConfig))
+	proxyInformer.Informer().AddEventHandler(optr.eventHandler(isClusterConfig))
+	infrastructureInformer.Informer().AddEventHandler(optr.eventHandler(isClusterConfig))
+	if options.Standalone {
+		// The ClusterOperator API may not even exist without the
+		// cluster-version-operator, the annotations are set on the status configmap.
+		configMapInformer.Informer().AddEventHandler(optr.eventHandlerForceSync(isStandaloneStatusConfigMap))
+	} else {
+		clusterOperatorInformer.Informer().AddEventHandler(optr.eventHandlerForceSync(isMachineAPIClusterOperator))
+	}
+	secretInformer.Informer().AddEventHandler(optr.eventHandler(isProviderCredentialsSecret))
 
 	optr.config = config
+	optr.options = options
+	optr.health = options.Health
+	if optr.health == nil {
+		optr.health = &Health{}
+	}
+	optr.cacheSyncTimeout = cacheSyncTimeout
+	optr.startupJitter = startupJitter
 	optr.syncHandler = optr.sync
+	optr.reconcilers = optr.defaultReconcilers()
 
 	optr.deployLister = deployInformer.Lister()
 	optr.deployListerSynced = deployInformer.Informer().HasSynced
@@ -132,6 +235,8 @@ func New(
 	optr.proxyLister = proxyInformer.Lister()
 	optr.proxyListerSynced = proxyInformer.Informer().HasSynced
 
+	optr.infrastructureListerSynced = infrastructureInformer.Informer().HasSynced
+
 	optr.validatingWebhookLister = validatingWebhookInformer.Lister()
 	optr.validatingWebhookListerSynced = validatingWebhookInformer.Informer().HasSynced
 
@@ -141,91 +246,334 @@ func New(
 	optr.featureGateLister = featureGateInformer.Lister()
 	optr.featureGateCacheSynced = featureGateInformer.Informer().HasSynced
 
+	optr.secretLister = secretInformer.Lister()
+	optr.secretListerSynced = secretInformer.Informer().HasSynced
+
 	return optr
 }
 
+// newRateLimiter mirrors workqueue.DefaultControllerRateLimiter with a configurable per-item backoff.
+func newRateLimiter(baseBackoff, maxBackoff time.Duration) workqueue.RateLimiter {
+	return workqueue.NewMaxOfRateLimiter(
+		workqueue.NewItemExponentialFailureRateLimiter(baseBackoff, maxBackoff),
+		// 10 qps, 100 bucket size. This is only for retry speed and its only the overall factor (not per item)
+		&workqueue.BucketRateLimiter{Limiter: rate.NewLimiter(rate.Limit(10), 100)},
+	)
+}
+
 // Run runs the machine config operator.
 func (optr *Operator) Run(workers int, stopCh <-chan struct{}) {
 	defer utilruntime.HandleCrash()
 	defer optr.queue.ShutDown()
 
-	klog.Info("Starting Machine API Operator")
-	defer klog.Info("Shutting down Machine API Operator")
-
-	if !cache.WaitForCacheSync(stopCh,
-		optr.mutatingWebhookListerSynced,
-		optr.validatingWebhookListerSynced,
-		optr.deployListerSynced,
-		optr.daemonsetListerSynced,
-		optr.proxyListerSynced,
-		optr.featureGateCacheSynced) {
-		klog.Error("Failed to sync caches")
-		return
+	klog.InfoS("Starting Machine API Operator", "namespace", optr.namespace, "workers", workers)
+	defer klog.InfoS("Shutting down Machine API Operator", "namespace", optr.namespace)
+
+	// ctx is cancelled only once the workers are drained (or the drain
+	// times out), so in-flight syncs are not interrupted by stopCh alone.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The caches are synced from scratch, the pod is unready until they are.
+	optr.health.setLeading()
+
+	// Once Run returns the operator doesn't reconcile anymore, fail the
+	// liveness probe so the pod is restarted if the process keeps running.
+	defer optr.health.setStopped()
+
+	if err := optr.waitForCacheSync(stopCh); err != nil {
+		klog.ErrorS(err, "Failed to sync caches", "namespace", optr.namespace)
+		select {
+		case <-stopCh:
+			return
+		default:
+			if err := optr.statusDegraded(ctx, err); err != nil {
+				klog.ErrorS(err, "Error syncing ClusterOperatorStatus")
+			}
+		}
+		// Keep waiting rather than giving up, this replica holds the leader
+		// lease and no other one would reconcile in the meantime.
+		if !cache.WaitForCacheSync(stopCh, optr.cacheSyncInformers().synced()...) {
+			return
+		}
 	}
-	klog.Info("Synced up caches")
+	klog.InfoS("Synced up caches", "namespace", optr.namespace)
+	optr.health.setCachesSynced()
+
+	// The workers and the resync start after a random delay each, so that
+	// operators restarting together don't all hit the API server at once.
+	var wg sync.WaitGroup
 	for i := 0; i < workers; i++ {
-		go wait.Until(optr.worker, time.Second, stopCh)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sleepJitter(optr.startupJitter, stopCh)
+			wait.Until(func() { optr.worker(ctx) }, time.Second, stopCh)
+		}()
+	}
+	go optr.watchImagesFiles(stopCh)
+	if optr.options.ResyncInterval > 0 {
+		go func() {
+			sleepJitter(optr.startupJitter, stopCh)
+			wait.Until(optr.resync, optr.options.ResyncInterval, stopCh)
+		}()
 	}
 
 	<-stopCh
+
+	// Stop handing out new work and let the in-flight syncs finish,
+	// so that operands are not left half applied.
+	optr.queue.ShutDown()
+	optr.waitForWorkers(&wg)
+}
+
+// namedInformers are informers named after the resources they watch.
+type namedInformers []struct {
+	name   string
+	synced cache.InformerSynced
+}
+
+func (informers namedInformers) synced() []cache.InformerSynced {
+	synced := make([]cache.InformerSynced, 0, len(informers))
+	for _, informer := range informers {
+		synced = append(synced, informer.synced)
+	}
+	return synced
+}
+
+// cacheSyncInformers returns the informers whose caches are waited for before
+// the workers start.
+func (optr *Operator) cacheSyncInformers() namedInformers {
+	return namedInformers{
+		{"mutatingwebhookconfigurations", optr.mutatingWebhookListerSynced},
+		{"validatingwebhookconfigurations", optr.validatingWebhookListerSynced},
+		{"deployments", optr.deployListerSynced},
+		{"daemonsets", optr.daemonsetListerSynced},
+		{"proxies", optr.proxyListerSynced},
+		{"infrastructures", optr.infrastructureListerSynced},
+		{"featuregates", optr.featureGateCacheSynced},
+		{"secrets", optr.secretListerSynced},
+	}
+}
+
+// waitForCacheSync waits for the informer caches to sync until cacheSyncTimeout
+// passes or stopCh is closed. The error names the informers which did not sync.
+func (optr *Operator) waitForCacheSync(stopCh <-chan struct{}) error {
+	informers := optr.cacheSyncInformers()
+	ctx, cancel := context.WithTimeout(context.Background(), optr.cacheSyncTimeout)
+	defer cancel()
+	go func() {
+		select {
+		case <-stopCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	if cache.WaitForCacheSync(ctx.Done(), informers.synced()...) {
+		return nil
+	}
+
+	var unsynced []string
+	for _, informer := range informers {
+		if !informer.synced() {
+			unsynced = append(unsynced, informer.name)
+		}
+	}
+	return fmt.Errorf("caches did not sync within %v: %s", optr.cacheSyncTimeout, strings.Join(unsynced, ", "))
+}
+
+// waitForWorkers waits up to drainTimeout for the workers to return.
+func (optr *Operator) waitForWorkers(wg *sync.WaitGroup) {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		klog.Info("All workers finished")
+	case <-time.After(optr.options.DrainTimeout):
+		klog.Warningf("Timed out after %v waiting for workers to finish", optr.options.DrainTimeout)
+	}
+}
+
+// resync enqueues a full sync so drift in the operands is corrected even when
+// no watch event is received for it.
+func (optr *Operator) resync() {
+	klog.V(4).Info("Enqueueing periodic resync")
+	optr.enqueue(optr.workKey(syncScopeFull))
+}
+
+// syncScope narrows what a sync reconciles.
+type syncScope string
+
+const (
+	// syncScopeFull reconciles every operand and the ClusterOperator status.
+	syncScopeFull syncScope = "full"
+	// syncScopeWebhooks only reconciles the machine API webhook configurations.
+	syncScopeWebhooks syncScope = "webhooks"
+)
+
+// workKey is the item held by the queue. Keys are compared by value, so
+// pending syncs of the same scope are coalesced while syncs of different
+// scopes are kept apart.
+type workKey struct {
+	name  string
+	scope syncScope
+}
+
+func (k workKey) String() string {
+	return fmt.Sprintf("%s (%s)", k.name, k.scope)
+}
+
+// workKey returns the key for a sync of the operator with the given scope.
+func (optr *Operator) workKey(scope syncScope) workKey {
+	return workKey{name: fmt.Sprintf("%s/%s", optr.namespace, optr.name), scope: scope}
+}
+
+// enqueue adds key to the queue once syncDebounce has passed. Any further enqueue
+// of the key within that window is coalesced into the same sync, so a burst of
+// events results in a single sync.
+func (optr *Operator) enqueue(key workKey) {
+	optr.queue.AddAfter(key, optr.options.SyncDebounce)
+}
+
+// sleepJitter waits for a random duration below max, or until stopCh is closed.
+func sleepJitter(max time.Duration, stopCh <-chan struct{}) {
+	if max <= 0 {
+		return
+	}
+	select {
+	case <-time.After(time.Duration(utilrand.Int63nRange(0, int64(max)))):
+	case <-stopCh:
+	}
+}
+
+// watchImagesFiles enqueues a sync whenever any of the images files changes on disk.
+// The parent directories are watched rather than the files themselves, as mounted
+// configmaps are updated by swapping symlinks which would drop a file watch.
+func (optr *Operator) watchImagesFiles(stopCh <-chan struct{}) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		klog.Errorf("Failed to create images file watcher: %v", err)
+		return
+	}
+	defer watcher.Close()
+
+	for _, imagesFile := range optr.imagesFiles {
+		dir := filepath.Dir(imagesFile)
+		if err := watcher.Add(dir); err != nil {
+			// Optional images files may live in a directory which does not exist yet.
+			klog.Errorf("Failed to watch images file directory %q: %v", dir, err)
+		}
+	}
+
+	workQueueKey := optr.workKey(syncScopeFull)
+	for {
+		select {
+		case <-stopCh:
+			return
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			klog.V(4).Infof("Images file event: %v", event)
+			optr.enqueue(workQueueKey)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			// Errors from the watcher are transient, keep watching.
+			klog.Errorf("Error watching images files %v: %v", optr.imagesFiles, err)
+		}
+	}
 }
 
 func logResource(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
 	metaObj, okObject := obj.(metav1.Object)
 	if !okObject {
 		klog.Errorf("Error assigning type to interface when logging")
+		return
 	}
 	klog.V(4).Infof("Resource type: %T", obj)
 	klog.V(4).Infof("Resource: %v", metaObj.GetSelfLink())
 }
 
-func (optr *Operator) eventHandler() cache.ResourceEventHandler {
-	workQueueKey := fmt.Sprintf("%s/%s", optr.namespace, optr.name)
-	return cache.ResourceEventHandlerFuncs{
-		AddFunc: func(obj interface{}) {
-			klog.V(4).Infof("Event: Add")
-			logResource(obj)
-			optr.queue.Add(workQueueKey)
-		},
-		UpdateFunc: func(old, new interface{}) {
-			klog.V(4).Infof("Event: Update")
-			logResource(old)
-			optr.queue.Add(workQueueKey)
-		},
-		DeleteFunc: func(obj interface{}) {
-			klog.V(4).Infof("Event: Delete")
-			logResource(obj)
-			optr.queue.Add(workQueueKey)
+// eventHandler enqueues a full sync for the objects f accepts.
+func (optr *Operator) eventHandler(f func(interface{}) bool) cache.FilteringResourceEventHandler {
+	workQueueKey := optr.workKey(syncScopeFull)
+	return cache.FilteringResourceEventHandler{
+		FilterFunc: f,
+		Handler: cache.ResourceEventHandlerFuncs{
+			AddFunc: func(obj interface{}) {
+				klog.V(4).Infof("Event: Add")
+				logResource(obj)
+				optr.enqueue(workQueueKey)
+			},
+			UpdateFunc: func(old, new interface{}) {
+				klog.V(4).Infof("Event: Update")
+				logResource(old)
+				optr.enqueue(workQueueKey)
+			},
+			DeleteFunc: func(obj interface{}) {
+				klog.V(4).Infof("Event: Delete")
+				logResource(obj)
+				optr.enqueue(workQueueKey)
+			},
 		},
 	}
 }
 
-// on deployments we only reconcile on update/delete events if its owned by mao
-func (optr *Operator) eventHandlerDeployments() cache.ResourceEventHandler {
-	workQueueKey := fmt.Sprintf("%s/%s", optr.namespace, optr.name)
-	return cache.ResourceEventHandlerFuncs{
-		AddFunc: func(obj interface{}) {
-			klog.V(4).Infof("Event: Add")
-			logResource(obj)
-			optr.queue.Add(workQueueKey)
-		},
-		UpdateFunc: func(old, new interface{}) {
-			klog.V(4).Infof("Event: Update")
-			logResource(old)
-			if owned, err := isOwned(old); !owned || err != nil {
-				return
-			}
-			optr.queue.Add(workQueueKey)
-		},
-		DeleteFunc: func(obj interface{}) {
-			klog.V(4).Infof("Event: Delete")
-			logResource(obj)
-			if owned, err := isOwned(obj); !owned || err != nil {
-				return
-			}
-			optr.queue.Add(workQueueKey)
-		},
+// isManagedByOperator reports whether obj is one of the operands, so that
+// changes to unrelated objects in the namespace don't trigger a sync. Objects
+// applied before the managed-by label was introduced are recognised by the
+// owned annotation.
+func isManagedByOperator(obj interface{}) bool {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	metaObj, ok := obj.(metav1.Object)
+	if !ok {
+		return false
 	}
+	if metaObj.GetLabels()[managedByLabel] == managedByLabelValue {
+		return true
+	}
+	owned, _ := isOwned(obj)
+	return owned
+}
+
+// isClusterConfig reports whether obj is the cluster-wide config object
+// the operator reads, as opposed to any other object of its kind.
+func isClusterConfig(obj interface{}) bool {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	metaObj, ok := obj.(metav1.Object)
+	return ok && metaObj.GetName() == "cluster"
+}
+
+// isProviderCredentialsSecret reports whether obj is the cloud credentials
+// secret of the machine controller of any platform.
+func isProviderCredentialsSecret(obj interface{}) bool {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	secret, ok := obj.(*corev1.Secret)
+	if !ok {
+		return false
+	}
+	for _, name := range providerCredentialsSecrets {
+		if secret.Name == name {
+			return true
+		}
+	}
+	return false
 }
 
 func isOwned(obj interface{}) (bool, error) {
@@ -238,11 +586,12 @@ func isOwned(obj interface{}) (bool, error) {
 	return ok, nil
 }
 
-func (optr *Operator) eventHandlerSingleton(f func(interface{}) bool) cache.FilteringResourceEventHandler {
-	workQueueKey := fmt.Sprintf("%s/%s", optr.namespace, optr.name)
+// eventHandlerSingleton enqueues a sync with the given scope for the objects f accepts.
+func (optr *Operator) eventHandlerSingleton(f func(interface{}) bool, scope syncScope) cache.FilteringResourceEventHandler {
+	workQueueKey := optr.workKey(scope)
 	addToQueue := func(obj interface{}) {
 		logResource(obj)
-		optr.queue.Add(workQueueKey)
+		optr.enqueue(workQueueKey)
 	}
 
 	return cache.FilteringResourceEventHandler{
@@ -257,13 +606,48 @@ func (optr *Operator) eventHandlerSingleton(f func(interface{}) bool) cache.Filt
 	}
 }
 
+// eventHandlerForceSync enqueues a full sync, without waiting for the debounce
+// window, when the force-sync annotation of the object f accepts changes: the
+// machine-api ClusterOperator, or the status configmap when running standalone.
+func (optr *Operator) eventHandlerForceSync(f func(interface{}) bool) cache.FilteringResourceEventHandler {
+	return cache.FilteringResourceEventHandler{
+		FilterFunc: f,
+		Handler: cache.ResourceEventHandlerFuncs{
+			UpdateFunc: func(old, new interface{}) {
+				oldNonce := old.(metav1.Object).GetAnnotations()[forceSyncAnnotation]
+				newNonce, ok := new.(metav1.Object).GetAnnotations()[forceSyncAnnotation]
+				if !ok || newNonce == oldNonce {
+					return
+				}
+				klog.InfoS("Forcing a full sync", "annotation", forceSyncAnnotation, "nonce", newNonce)
+				optr.queue.Add(optr.workKey(syncScopeFull))
+			},
+		},
+	}
+}
+
+func isMachineAPIClusterOperator(obj interface{}) bool {
+	co, ok := obj.(*osconfigv1.ClusterOperator)
+	return ok && co.Name == clusterOperatorName
+}
+
+func isStandaloneStatusConfigMap(obj interface{}) bool {
+	cm, ok := obj.(*corev1.ConfigMap)
+	return ok && cm.Name == standaloneStatusConfigMapName
+}
+
+// isMachineWebhook reports whether obj is the validating or mutating webhook
+// configuration of the machine-api.
 func isMachineWebhook(obj interface{}) bool {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
 	mutatingWebhook, ok := obj.(*admissionregistrationv1.MutatingWebhookConfiguration)
 	if ok {
 		return mutatingWebhook.Name == "machine-api"
 	}
 
-	validatingWebhook, ok := obj.(*admissionregistrationv1.MutatingWebhookConfiguration)
+	validatingWebhook, ok := obj.(*admissionregistrationv1.ValidatingWebhookConfiguration)
 	if ok {
 		return validatingWebhook.Name == "machine-api"
 	}
@@ -271,59 +655,124 @@ func isMachineWebhook(obj interface{}) bool {
 	return false
 }
 
-func (optr *Operator) worker() {
-	for optr.processNextWorkItem() {
+func (optr *Operator) worker(ctx context.Context) {
+	for optr.processNextWorkItem(ctx) {
 	}
 }
 
-func (optr *Operator) processNextWorkItem() bool {
+func (optr *Operator) processNextWorkItem(ctx context.Context) bool {
 	key, quit := optr.queue.Get()
 	if quit {
 		return false
 	}
 	defer optr.queue.Done(key)
 
-	klog.V(4).Infof("Processing key %s", key)
-	err := optr.syncHandler(key.(string))
-	optr.handleErr(err, key)
+	// Once shutdown started only the in-flight syncs are drained, the keys
+	// still queued are dropped rather than starting new syncs.
+	if optr.queue.ShuttingDown() {
+		klog.V(4).InfoS("Skipping key, shutting down", "key", key)
+		return true
+	}
+
+	// Keys of different scopes apply some of the same objects and all report
+	// status on the ClusterOperator, so only one of them is synced at a time.
+	optr.syncLock.Lock()
+	defer optr.syncLock.Unlock()
+
+	klog.V(4).InfoS("Processing key", "key", key)
+	syncCtx, cancel := context.WithTimeout(ctx, optr.syncTimeout())
+	defer cancel()
+	err := optr.syncHandler(syncCtx, key.(workKey))
+	// The failure is reported with the worker context, as syncCtx may have
+	// timed out already.
+	optr.handleErr(ctx, err, key)
 
 	return true
 }
 
-func (optr *Operator) handleErr(err error, key interface{}) {
+// syncTimeout bounds a single sync so a hung API call can't hold a worker forever.
+// It leaves room for both operand rollouts plus the API calls around them.
+func (optr *Operator) syncTimeout() time.Duration {
+	return 2*optr.options.RolloutTimeout + syncAPICallsTimeout
+}
+
+func (optr *Operator) handleErr(ctx context.Context, err error, key interface{}) {
 	if err == nil {
+		metrics.ObserveMachineAPIOperatorSyncSuccess()
+		optr.queue.Forget(key)
+		return
+	}
+
+	metrics.ObserveMachineAPIOperatorSyncFailed()
+	if isPermanentError(err) {
+		utilruntime.HandleError(err)
+		klog.ErrorS(err, "Not retrying operator sync on permanent error", "key", key)
 		optr.queue.Forget(key)
+		if err := optr.statusDegraded(ctx, err); err != nil {
+			klog.ErrorS(err, "Error syncing ClusterOperatorStatus", "key", key)
+		}
 		return
 	}
 
-	if optr.queue.NumRequeues(key) < maxRetries {
-		klog.V(1).Infof("Error syncing operator %v: %v", key, err)
+	if optr.queue.NumRequeues(key) < optr.options.MaxRetries {
+		klog.ErrorS(err, "Error syncing operator", "key", key, "retries", optr.queue.NumRequeues(key))
+		metrics.ObserveMachineAPIOperatorSyncRetry()
 		optr.queue.AddRateLimited(key)
 		return
 	}
 
 	utilruntime.HandleError(err)
-	klog.V(1).Infof("Dropping operator %q out of the queue: %v", key, err)
+	klog.ErrorS(err, "Dropping operator out of the queue", "key", key)
 	optr.queue.Forget(key)
+
+	// Retries are exhausted, make sure the failure is visible on the
+	// ClusterOperator even if the sync failed before reporting it.
+	if err := optr.statusDegraded(ctx, err); err != nil {
+		klog.ErrorS(err, "Error syncing ClusterOperatorStatus", "key", key)
+	}
 }
 
-func (optr *Operator) sync(key string) error {
+func (optr *Operator) sync(ctx context.Context, key workKey) error {
 	startTime := time.Now()
-	klog.V(4).Infof("Started syncing operator %q (%v)", key, startTime)
+	klog.V(4).InfoS("Started syncing operator", "key", key, "namespace", optr.namespace, "startTime", startTime)
 	defer func() {
-		klog.V(4).Infof("Finished syncing operator %q (%v)", key, time.Since(startTime))
+		duration := time.Since(startTime)
+		metrics.ObserveMachineAPIOperatorSyncDuration(duration)
+		klog.V(4).InfoS("Finished syncing operator", "key", key, "namespace", optr.namespace, "duration", duration)
 	}()
 
-	operatorConfig, err := optr.maoConfigFromInfrastructure()
+	co, err := optr.getClusterOperator(ctx)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	if err == nil {
+		if _, paused := co.Annotations[pausedAnnotation]; paused {
+			klog.InfoS("Reconciliation is paused, skipping sync", "key", key, "annotation", pausedAnnotation)
+			return optr.statusPaused(ctx, co)
+		}
+	}
+
+	operatorConfig, err := optr.maoConfigFromInfrastructure(ctx)
 	if err != nil {
-		klog.Errorf("Failed getting operator config: %v", err)
+		if err := optr.statusDegraded(ctx, err); err != nil {
+			// Just log the error here.  We still want to
+			// return the outer error.
+			klog.ErrorS(err, "Error syncing ClusterOperatorStatus", "key", key)
+		}
+		klog.ErrorS(err, "Failed getting operator config", "key", key)
 		return err
 	}
-	return optr.syncAll(operatorConfig)
+	if key.scope == syncScopeWebhooks && !optr.options.Observe && operatorConfig.Controllers.Provider != clusterAPIControllerNoOp {
+		return optr.syncWebhooksOnly(ctx, operatorConfig)
+	}
+	return optr.syncAll(ctx, operatorConfig)
 }
 
-func (optr *Operator) maoConfigFromInfrastructure() (*OperatorConfig, error) {
-	infra, err := optr.osClient.ConfigV1().Infrastructures().Get(context.Background(), "cluster", metav1.GetOptions{})
+func (optr *Operator) maoConfigFromInfrastructure(ctx context.Context) (*OperatorConfig, error) {
+	infra, err := optr.osClient.ConfigV1().Infrastructures().Get(ctx, "cluster", metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("%w: %v", ErrConfigNotFound, err)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -332,11 +781,20 @@ func (optr *Operator) maoConfigFromInfrastructure() (*OperatorConfig, error) {
 	if err != nil {
 		return nil, err
 	}
+	klog.V(2).InfoS("Detected platform provider", "provider", provider)
 
-	images, err := getImagesFromJSONFile(optr.imagesFile)
+	images, err := getImagesFromJSONFiles(optr.imagesFiles)
 	if err != nil {
 		return nil, err
 	}
+	if optr.options.DefaultMissingImages {
+		// Warn when the images files change, not on every sync.
+		defaulted := strings.Join(applyImageDefaults(images), ", ")
+		if defaulted != "" && defaulted != optr.defaultedImages {
+			klog.Warningf("Images missing from the images files, using defaults: %s", defaulted)
+		}
+		optr.defaultedImages = defaulted
+	}
 
 	providerControllerImage, err := getProviderControllerFromImages(provider, *images)
 	if err != nil {
@@ -358,14 +816,27 @@ func (optr *Operator) maoConfigFromInfrastructure() (*OperatorConfig, error) {
 		return nil, err
 	}
 
-	clusterWideProxy, err := optr.osClient.ConfigV1().Proxies().Get(context.Background(), "cluster", metav1.GetOptions{})
+	clusterWideProxy, err := optr.osClient.ConfigV1().Proxies().Get(ctx, "cluster", metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("%w: %v", ErrConfigNotFound, err)
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	return &OperatorConfig{
-		TargetNamespace: optr.namespace,
-		Proxy:           clusterWideProxy,
+	config := &OperatorConfig{
+		TargetNamespace:           optr.namespace,
+		PlatformType:              provider,
+		Proxy:                     clusterWideProxy,
+		ControllerReplicas:        optr.options.ControllerReplicas,
+		Tolerations:               optr.options.ControllerTolerations,
+		TopologySpreadConstraints: optr.options.ControllerTopologySpreadConstraints,
+		ExtraVolumes:              optr.options.ControllerExtraVolumes,
+		ExtraVolumeMounts:         optr.options.ControllerExtraVolumeMounts,
+		InitContainers:            optr.options.ControllerInitContainers,
+		ImagePullPolicy:           optr.options.ImagePullPolicy,
+		Resources:                 optr.options.Resources,
+		AllowedNamespaces:         optr.options.AllowedNamespaces,
 		Controllers: Controllers{
 			Provider:           providerControllerImage,
 			MachineSet:         machineAPIOperatorImage,
@@ -374,5 +845,21 @@ func (optr *Operator) maoConfigFromInfrastructure() (*OperatorConfig, error) {
 			KubeRBACProxy:      kubeRBACProxy,
 			TerminationHandler: terminationHandlerImage,
 		},
-	}, nil
+	}
+
+	if optr.options.RequireImageDigests {
+		if err := validateImageDigests(config.Controllers); err != nil {
+			return nil, err
+		}
+	}
+	if err := validateExtraVolumes(config); err != nil {
+		return nil, NewPermanentError(fmt.Errorf("invalid extra volumes: %w", err))
+	}
+	if err := validateResources(config); err != nil {
+		return nil, NewPermanentError(fmt.Errorf("invalid resources: %w", err))
+	}
+	if err := validateInitContainers(config); err != nil {
+		return nil, NewPermanentError(fmt.Errorf("invalid init containers: %w", err))
+	}
+	return config, nil
 }
@@ -7,7 +7,8 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
-	"syscall"
+	"path/filepath"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -15,17 +16,20 @@ import (
 	openshiftv1 "github.com/openshift/api/config/v1"
 	fakeos "github.com/openshift/client-go/config/clientset/versioned/fake"
 	configinformersv1 "github.com/openshift/client-go/config/informers/externalversions"
+	"github.com/openshift/library-go/pkg/config/clusteroperator/v1helpers"
 	"github.com/stretchr/testify/assert"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
 	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/wait"
 	fakedynamic "k8s.io/client-go/dynamic/fake"
 	"k8s.io/client-go/informers"
 	fakekube "k8s.io/client-go/kubernetes/fake"
 	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/workqueue"
 )
@@ -46,38 +50,53 @@ func newFakeOperator(kubeObjects []runtime.Object, osObjects []runtime.Object, s
 	featureGateInformer := configSharedInformer.Config().V1().FeatureGates()
 	deployInformer := kubeNamespacedSharedInformer.Apps().V1().Deployments()
 	proxyInformer := configSharedInformer.Config().V1().Proxies()
+	infrastructureInformer := configSharedInformer.Config().V1().Infrastructures()
 	daemonsetInformer := kubeNamespacedSharedInformer.Apps().V1().DaemonSets()
 	mutatingWebhookInformer := kubeNamespacedSharedInformer.Admissionregistration().V1().MutatingWebhookConfigurations()
 	validatingWebhookInformer := kubeNamespacedSharedInformer.Admissionregistration().V1().ValidatingWebhookConfigurations()
+	secretInformer := kubeNamespacedSharedInformer.Core().V1().Secrets()
 
 	optr := &Operator{
-		kubeClient:                    kubeClient,
-		osClient:                      osClient,
-		dynamicClient:                 dynamicClient,
-		featureGateLister:             featureGateInformer.Lister(),
-		deployLister:                  deployInformer.Lister(),
-		proxyLister:                   proxyInformer.Lister(),
-		daemonsetLister:               daemonsetInformer.Lister(),
-		mutatingWebhookLister:         mutatingWebhookInformer.Lister(),
-		validatingWebhookLister:       validatingWebhookInformer.Lister(),
-		imagesFile:                    "fixtures/images.json",
-		namespace:                     targetNamespace,
-		eventRecorder:                 record.NewFakeRecorder(50),
-		queue:                         workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "machineapioperator"),
+		kubeClient:              kubeClient,
+		osClient:                osClient,
+		dynamicClient:           dynamicClient,
+		featureGateLister:       featureGateInformer.Lister(),
+		deployLister:            deployInformer.Lister(),
+		proxyLister:             proxyInformer.Lister(),
+		daemonsetLister:         daemonsetInformer.Lister(),
+		mutatingWebhookLister:   mutatingWebhookInformer.Lister(),
+		validatingWebhookLister: validatingWebhookInformer.Lister(),
+		secretLister:            secretInformer.Lister(),
+		imagesFiles:             []string{"fixtures/images.json"},
+		namespace:               targetNamespace,
+		eventRecorder:           record.NewFakeRecorder(50),
+		health:                  &Health{},
+		queue:                   workqueue.NewNamedRateLimitingQueue(newRateLimiter(DefaultBaseBackoff, DefaultMaxBackoff), "machineapioperator"),
+		options: Options{
+			MaxRetries:              DefaultMaxRetries,
+			RolloutPollInterval:     DefaultRolloutPollInterval,
+			RolloutTimeout:          DefaultRolloutTimeout,
+			ControllerReplicas:      DefaultControllerReplicas,
+			ControllersMinAvailable: DefaultControllersMinAvailable,
+		},
+		cacheSyncTimeout:              cacheSyncTimeout,
 		deployListerSynced:            deployInformer.Informer().HasSynced,
 		proxyListerSynced:             proxyInformer.Informer().HasSynced,
+		infrastructureListerSynced:    infrastructureInformer.Informer().HasSynced,
 		daemonsetListerSynced:         daemonsetInformer.Informer().HasSynced,
 		featureGateCacheSynced:        featureGateInformer.Informer().HasSynced,
 		mutatingWebhookListerSynced:   mutatingWebhookInformer.Informer().HasSynced,
 		validatingWebhookListerSynced: validatingWebhookInformer.Informer().HasSynced,
+		secretListerSynced:            secretInformer.Informer().HasSynced,
 	}
 
 	configSharedInformer.Start(stopCh)
 	kubeNamespacedSharedInformer.Start(stopCh)
 
 	optr.syncHandler = optr.sync
-	deployInformer.Informer().AddEventHandler(optr.eventHandlerDeployments())
-	featureGateInformer.Informer().AddEventHandler(optr.eventHandler())
+	optr.reconcilers = optr.defaultReconcilers()
+	deployInformer.Informer().AddEventHandler(optr.eventHandler(isManagedByOperator))
+	featureGateInformer.Informer().AddEventHandler(optr.eventHandler(isClusterConfig))
 
 	optr.operandVersions = []openshiftv1.OperandVersion{
 		{Name: "operator", Version: releaseVersion},
@@ -158,10 +177,16 @@ func TestOperatorSync_NoOp(t *testing.T) {
 
 			stopCh := make(<-chan struct{})
 			optr := newFakeOperator(nil, []runtime.Object{infra, proxy}, stopCh)
-			optr.queue.Add("trigger")
+			optr.queue.Add(optr.workKey(syncScopeFull))
 			go optr.Run(1, stopCh)
 
-			err := wait.PollImmediate(1*time.Second, 5*time.Second, func() (bool, error) {
+			// Wait for the sync to create the deployment or, on a no-op
+			// platform, to report available without it.
+			err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+				if tc.expectedNoop {
+					co, err := optr.getClusterOperator(context.Background())
+					return err == nil && v1helpers.IsStatusConditionTrue(co.Status.Conditions, openshiftv1.OperatorAvailable), nil
+				}
 				_, err := optr.deployLister.Deployments(targetNamespace).Get(deploymentName)
 				if err != nil {
 					t.Logf("Failed to get %q deployment: %v", deploymentName, err)
@@ -170,13 +195,14 @@ func TestOperatorSync_NoOp(t *testing.T) {
 				t.Logf("Found deployment: %q", deploymentName)
 				return true, nil
 			})
+			if !assert.NoError(t, err, "sync did not complete") {
+				t.Fatal()
+			}
 
 			var expectedConditions map[openshiftv1.ClusterStatusConditionType]openshiftv1.ConditionStatus
 
 			if tc.expectedNoop {
-				// The PollImmediate looking for the deployment above should
-				// have failed in the case of a no-op platform.
-				if err == nil {
+				if _, err := optr.deployLister.Deployments(targetNamespace).Get(deploymentName); err == nil {
 					t.Error("Found deployment when expecting no-op sync")
 				}
 
@@ -260,6 +286,148 @@ func TestIsOwned(t *testing.T) {
 	}
 }
 
+func TestIsManagedByOperator(t *testing.T) {
+	managed := &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{managedByLabel: managedByLabelValue},
+		},
+	}
+	testCases := []struct {
+		testCase string
+		obj      interface{}
+		expected bool
+	}{
+		{
+			testCase: "with managed-by label",
+			obj:      managed,
+			expected: true,
+		},
+		{
+			testCase: "with maoOwnedAnnotation",
+			obj: &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{maoOwnedAnnotation: ""},
+				},
+			},
+			expected: true,
+		},
+		{
+			testCase: "managed by someone else",
+			obj: &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{managedByLabel: "helm"},
+				},
+			},
+			expected: false,
+		},
+		{
+			testCase: "tombstone of a managed object",
+			obj:      cache.DeletedFinalStateUnknown{Key: "ns/name", Obj: managed},
+			expected: true,
+		},
+		{
+			testCase: "bad type object",
+			obj:      "bad object",
+			expected: false,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.testCase, func(t *testing.T) {
+			if got := isManagedByOperator(tc.obj); got != tc.expected {
+				t.Errorf("Expected: %v, got: %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestIsProviderCredentialsSecret(t *testing.T) {
+	credentials := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "aws-cloud-credentials", Namespace: targetNamespace},
+	}
+	testCases := []struct {
+		testCase string
+		obj      interface{}
+		expected bool
+	}{
+		{
+			testCase: "credentials secret",
+			obj:      credentials,
+			expected: true,
+		},
+		{
+			testCase: "other secret",
+			obj: &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "machine-api-operator-webhook-cert", Namespace: targetNamespace},
+			},
+			expected: false,
+		},
+		{
+			testCase: "tombstone of a credentials secret",
+			obj:      cache.DeletedFinalStateUnknown{Key: "ns/name", Obj: credentials},
+			expected: true,
+		},
+		{
+			testCase: "bad type object",
+			obj:      "bad object",
+			expected: false,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.testCase, func(t *testing.T) {
+			if got := isProviderCredentialsSecret(tc.obj); got != tc.expected {
+				t.Errorf("Expected: %v, got: %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestEventHandlerFiltersUnmanagedObjects(t *testing.T) {
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	optr := newFakeOperator(nil, nil, stopCh)
+	handler := optr.eventHandler(isManagedByOperator)
+
+	handler.OnAdd(&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "unrelated"}})
+	if optr.queue.Len() != 0 {
+		t.Fatalf("expected no sync for an unrelated object, got %d queued", optr.queue.Len())
+	}
+
+	handler.OnAdd(&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+		Name:   "machine-api-controllers",
+		Labels: map[string]string{managedByLabel: managedByLabelValue},
+	}})
+	if optr.queue.Len() != 1 {
+		t.Fatalf("expected a sync for a managed object, got %d queued", optr.queue.Len())
+	}
+}
+
+// TestInfrastructureChangeTriggersSync tests that a change to the cluster
+// Infrastructure, which the operator config is built from, enqueues a sync.
+func TestInfrastructureChangeTriggersSync(t *testing.T) {
+	g := NewWithT(t)
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	optr := newFakeOperator(nil, nil, stopCh)
+
+	configSharedInformer := configinformersv1.NewSharedInformerFactory(optr.osClient, 0)
+	infrastructureInformer := configSharedInformer.Config().V1().Infrastructures()
+	infrastructureInformer.Informer().AddEventHandler(optr.eventHandler(isClusterConfig))
+	configSharedInformer.Start(stopCh)
+	g.Expect(cache.WaitForCacheSync(stopCh, infrastructureInformer.Informer().HasSynced)).To(BeTrue())
+
+	_, err := optr.osClient.ConfigV1().Infrastructures().Create(context.Background(), &openshiftv1.Infrastructure{
+		ObjectMeta: metav1.ObjectMeta{Name: "other"},
+	}, metav1.CreateOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Consistently(optr.queue.Len, 200*time.Millisecond).Should(Equal(0))
+
+	_, err = optr.osClient.ConfigV1().Infrastructures().Create(context.Background(), &openshiftv1.Infrastructure{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster"},
+	}, metav1.CreateOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Eventually(optr.queue.Len).Should(Equal(1))
+}
+
 // TestMAOConfigFromInfrastructure tests that the expected config comes back
 // for the given infrastructure
 func TestMAOConfigFromInfrastructure(t *testing.T) {
@@ -298,8 +466,10 @@ func TestMAOConfigFromInfrastructure(t *testing.T) {
 			infra:    infra,
 			proxy:    proxy,
 			expectedConfig: &OperatorConfig{
-				TargetNamespace: targetNamespace,
-				Proxy:           proxy,
+				TargetNamespace:    targetNamespace,
+				PlatformType:       openshiftv1.AWSPlatformType,
+				Proxy:              proxy,
+				ControllerReplicas: DefaultControllerReplicas,
 				Controllers: Controllers{
 					Provider:           images.ClusterAPIControllerAWS,
 					MachineSet:         images.MachineAPIOperator,
@@ -316,8 +486,10 @@ func TestMAOConfigFromInfrastructure(t *testing.T) {
 			infra:    infra,
 			proxy:    proxy,
 			expectedConfig: &OperatorConfig{
-				TargetNamespace: targetNamespace,
-				Proxy:           proxy,
+				TargetNamespace:    targetNamespace,
+				PlatformType:       openshiftv1.LibvirtPlatformType,
+				Proxy:              proxy,
+				ControllerReplicas: DefaultControllerReplicas,
 				Controllers: Controllers{
 					Provider:           images.ClusterAPIControllerLibvirt,
 					MachineSet:         images.MachineAPIOperator,
@@ -334,8 +506,10 @@ func TestMAOConfigFromInfrastructure(t *testing.T) {
 			infra:    infra,
 			proxy:    proxy,
 			expectedConfig: &OperatorConfig{
-				TargetNamespace: targetNamespace,
-				Proxy:           proxy,
+				TargetNamespace:    targetNamespace,
+				PlatformType:       openshiftv1.OpenStackPlatformType,
+				Proxy:              proxy,
+				ControllerReplicas: DefaultControllerReplicas,
 				Controllers: Controllers{
 					Provider:           images.ClusterAPIControllerOpenStack,
 					MachineSet:         images.MachineAPIOperator,
@@ -352,8 +526,10 @@ func TestMAOConfigFromInfrastructure(t *testing.T) {
 			infra:    infra,
 			proxy:    proxy,
 			expectedConfig: &OperatorConfig{
-				TargetNamespace: targetNamespace,
-				Proxy:           proxy,
+				TargetNamespace:    targetNamespace,
+				PlatformType:       openshiftv1.AzurePlatformType,
+				Proxy:              proxy,
+				ControllerReplicas: DefaultControllerReplicas,
 				Controllers: Controllers{
 					Provider:           images.ClusterAPIControllerAzure,
 					MachineSet:         images.MachineAPIOperator,
@@ -370,8 +546,10 @@ func TestMAOConfigFromInfrastructure(t *testing.T) {
 			infra:    infra,
 			proxy:    proxy,
 			expectedConfig: &OperatorConfig{
-				TargetNamespace: targetNamespace,
-				Proxy:           proxy,
+				TargetNamespace:    targetNamespace,
+				PlatformType:       openshiftv1.BareMetalPlatformType,
+				Proxy:              proxy,
+				ControllerReplicas: DefaultControllerReplicas,
 				Controllers: Controllers{
 					Provider:           images.ClusterAPIControllerBareMetal,
 					MachineSet:         images.MachineAPIOperator,
@@ -388,8 +566,10 @@ func TestMAOConfigFromInfrastructure(t *testing.T) {
 			infra:    infra,
 			proxy:    proxy,
 			expectedConfig: &OperatorConfig{
-				TargetNamespace: targetNamespace,
-				Proxy:           proxy,
+				TargetNamespace:    targetNamespace,
+				PlatformType:       openshiftv1.GCPPlatformType,
+				Proxy:              proxy,
+				ControllerReplicas: DefaultControllerReplicas,
 				Controllers: Controllers{
 					Provider:           images.ClusterAPIControllerGCP,
 					MachineSet:         images.MachineAPIOperator,
@@ -406,8 +586,10 @@ func TestMAOConfigFromInfrastructure(t *testing.T) {
 			infra:    infra,
 			proxy:    proxy,
 			expectedConfig: &OperatorConfig{
-				TargetNamespace: targetNamespace,
-				Proxy:           proxy,
+				TargetNamespace:    targetNamespace,
+				PlatformType:       kubemarkPlatform,
+				Proxy:              proxy,
+				ControllerReplicas: DefaultControllerReplicas,
 				Controllers: Controllers{
 					Provider:           clusterAPIControllerKubemark,
 					MachineSet:         images.MachineAPIOperator,
@@ -424,8 +606,10 @@ func TestMAOConfigFromInfrastructure(t *testing.T) {
 			infra:    infra,
 			proxy:    proxy,
 			expectedConfig: &OperatorConfig{
-				TargetNamespace: targetNamespace,
-				Proxy:           proxy,
+				TargetNamespace:    targetNamespace,
+				PlatformType:       openshiftv1.VSpherePlatformType,
+				Proxy:              proxy,
+				ControllerReplicas: DefaultControllerReplicas,
 				Controllers: Controllers{
 					Provider:           images.ClusterAPIControllerVSphere,
 					MachineSet:         images.MachineAPIOperator,
@@ -442,8 +626,10 @@ func TestMAOConfigFromInfrastructure(t *testing.T) {
 			infra:    infra,
 			proxy:    proxy,
 			expectedConfig: &OperatorConfig{
-				TargetNamespace: targetNamespace,
-				Proxy:           proxy,
+				TargetNamespace:    targetNamespace,
+				PlatformType:       openshiftv1.OvirtPlatformType,
+				Proxy:              proxy,
+				ControllerReplicas: DefaultControllerReplicas,
 				Controllers: Controllers{
 					Provider:           images.ClusterAPIControllerOvirt,
 					MachineSet:         images.MachineAPIOperator,
@@ -460,8 +646,10 @@ func TestMAOConfigFromInfrastructure(t *testing.T) {
 			infra:    infra,
 			proxy:    proxy,
 			expectedConfig: &OperatorConfig{
-				TargetNamespace: targetNamespace,
-				Proxy:           proxy,
+				TargetNamespace:    targetNamespace,
+				PlatformType:       openshiftv1.NonePlatformType,
+				Proxy:              proxy,
+				ControllerReplicas: DefaultControllerReplicas,
 				Controllers: Controllers{
 					Provider:           clusterAPIControllerNoOp,
 					MachineSet:         images.MachineAPIOperator,
@@ -478,8 +666,10 @@ func TestMAOConfigFromInfrastructure(t *testing.T) {
 			infra:    infra,
 			proxy:    proxy,
 			expectedConfig: &OperatorConfig{
-				TargetNamespace: targetNamespace,
-				Proxy:           proxy,
+				TargetNamespace:    targetNamespace,
+				PlatformType:       "bad-platform",
+				Proxy:              proxy,
+				ControllerReplicas: DefaultControllerReplicas,
 				Controllers: Controllers{
 					Provider:           clusterAPIControllerNoOp,
 					MachineSet:         images.MachineAPIOperator,
@@ -496,7 +686,7 @@ func TestMAOConfigFromInfrastructure(t *testing.T) {
 			infra:          nil,
 			proxy:          proxy,
 			expectedConfig: nil,
-			expectedError:  kerrors.NewNotFound(schema.GroupResource{Group: "config.openshift.io", Resource: "infrastructures"}, "cluster"),
+			expectedError:  ErrConfigNotFound,
 		},
 		{
 			name:           "no-proxy",
@@ -504,7 +694,7 @@ func TestMAOConfigFromInfrastructure(t *testing.T) {
 			infra:          infra,
 			proxy:          nil,
 			expectedConfig: nil,
-			expectedError:  kerrors.NewNotFound(schema.GroupResource{Group: "config.openshift.io", Resource: "proxies"}, "cluster"),
+			expectedError:  ErrConfigNotFound,
 		},
 		{
 			name:           "no-platform",
@@ -512,7 +702,7 @@ func TestMAOConfigFromInfrastructure(t *testing.T) {
 			infra:          infra,
 			proxy:          proxy,
 			expectedConfig: nil,
-			expectedError:  errors.New("no platform provider found on install config"),
+			expectedError:  ErrInvalidProvider,
 		},
 		{
 			name:           "no-images-file",
@@ -521,7 +711,7 @@ func TestMAOConfigFromInfrastructure(t *testing.T) {
 			proxy:          proxy,
 			imagesFile:     "fixtures/not-found.json",
 			expectedConfig: nil,
-			expectedError:  &os.PathError{Op: "open", Path: "fixtures/not-found.json", Err: syscall.ENOENT},
+			expectedError:  ErrImagesFileMissing,
 		},
 	}
 
@@ -542,15 +732,15 @@ func TestMAOConfigFromInfrastructure(t *testing.T) {
 			}
 			stopCh := make(<-chan struct{})
 			optr := newFakeOperator(nil, objects, stopCh)
-			optr.queue.Add("trigger")
+			optr.queue.Add(optr.workKey(syncScopeFull))
 
 			if tc.imagesFile != "" {
-				optr.imagesFile = tc.imagesFile
+				optr.imagesFiles = []string{tc.imagesFile}
 			}
 
 			go optr.Run(1, stopCh)
 
-			config, err := optr.maoConfigFromInfrastructure()
+			config, err := optr.maoConfigFromInfrastructure(context.Background())
 
 			if tc.expectedError != nil {
 				g.Expect(err).To(MatchError(tc.expectedError))
@@ -562,3 +752,431 @@ func TestMAOConfigFromInfrastructure(t *testing.T) {
 		})
 	}
 }
+
+// TestHandleErrDegraded tests that the operator reports degraded once it
+// runs out of retries for a key.
+func TestHandleErrDegraded(t *testing.T) {
+	g := NewWithT(t)
+
+	stopCh := make(<-chan struct{})
+	optr := newFakeOperator(nil, nil, stopCh)
+	syncErr := errors.New("sync failed")
+
+	for i := 0; i < DefaultMaxRetries; i++ {
+		optr.handleErr(context.Background(), syncErr, "trigger")
+		_, err := optr.getClusterOperator(context.Background())
+		g.Expect(kerrors.IsNotFound(err)).To(BeTrue(), "expected no clusteroperator while retrying")
+	}
+
+	optr.handleErr(context.Background(), syncErr, "trigger")
+	g.Expect(optr.queue.NumRequeues("trigger")).To(BeZero())
+
+	co, err := optr.getClusterOperator(context.Background())
+	g.Expect(err).ToNot(HaveOccurred())
+	degraded := v1helpers.FindStatusCondition(co.Status.Conditions, openshiftv1.OperatorDegraded)
+	g.Expect(degraded).ToNot(BeNil())
+	g.Expect(degraded.Status).To(Equal(openshiftv1.ConditionTrue))
+	g.Expect(degraded.Reason).To(Equal(string(ReasonSyncFailed)))
+	g.Expect(degraded.Message).To(ContainSubstring(syncErr.Error()))
+}
+
+func TestHandleErrMaxRetries(t *testing.T) {
+	g := NewWithT(t)
+
+	stopCh := make(<-chan struct{})
+	optr := newFakeOperator(nil, nil, stopCh)
+	optr.options.MaxRetries = 2
+	syncErr := errors.New("sync failed")
+
+	optr.handleErr(context.Background(), syncErr, "trigger")
+	optr.handleErr(context.Background(), syncErr, "trigger")
+	g.Expect(optr.queue.NumRequeues("trigger")).To(Equal(2))
+
+	optr.handleErr(context.Background(), syncErr, "trigger")
+	g.Expect(optr.queue.NumRequeues("trigger")).To(BeZero())
+	_, err := optr.getClusterOperator(context.Background())
+	g.Expect(err).ToNot(HaveOccurred())
+}
+
+func TestHandleErrPermanent(t *testing.T) {
+	g := NewWithT(t)
+
+	stopCh := make(<-chan struct{})
+	optr := newFakeOperator(nil, nil, stopCh)
+	syncErr := NewPermanentError(errors.New("invalid config"))
+
+	optr.handleErr(context.Background(), syncErr, "trigger")
+	g.Expect(optr.queue.NumRequeues("trigger")).To(BeZero())
+	g.Expect(optr.queue.Len()).To(BeZero(), "expected a permanent error not to be retried")
+
+	co, err := optr.getClusterOperator(context.Background())
+	g.Expect(err).ToNot(HaveOccurred())
+	degraded := v1helpers.FindStatusCondition(co.Status.Conditions, openshiftv1.OperatorDegraded)
+	g.Expect(degraded).ToNot(BeNil())
+	g.Expect(degraded.Status).To(Equal(openshiftv1.ConditionTrue))
+	g.Expect(degraded.Message).To(ContainSubstring(syncErr.Error()))
+}
+
+// TestWatchImagesFiles tests that changes to the images files enqueue a sync.
+func TestWatchImagesFiles(t *testing.T) {
+	g := NewWithT(t)
+
+	dir, err := ioutil.TempDir("", "images")
+	g.Expect(err).ToNot(HaveOccurred())
+	defer os.RemoveAll(dir)
+
+	imagesFile := filepath.Join(dir, "images.json")
+	g.Expect(ioutil.WriteFile(imagesFile, []byte("{}"), 0644)).To(Succeed())
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	optr := newFakeOperator(nil, nil, stopCh)
+	optr.imagesFiles = []string{imagesFile}
+
+	go optr.watchImagesFiles(stopCh)
+
+	g.Eventually(func() int {
+		// The watcher may not be established yet, keep touching the file.
+		g.Expect(ioutil.WriteFile(imagesFile, []byte(`{"machineAPIOperator": "test"}`), 0644)).To(Succeed())
+		return optr.queue.Len()
+	}, 5*time.Second).Should(Equal(1))
+}
+
+// TestOperatorSync_ConfigError tests that failing to build the operator config
+// is reported as degraded rather than only being logged.
+func TestOperatorSync_ConfigError(t *testing.T) {
+	g := NewWithT(t)
+
+	stopCh := make(<-chan struct{})
+	optr := newFakeOperator(nil, nil, stopCh)
+
+	err := optr.sync(context.Background(), optr.workKey(syncScopeFull))
+	g.Expect(err).To(HaveOccurred())
+
+	co, err := optr.getClusterOperator(context.Background())
+	g.Expect(err).ToNot(HaveOccurred())
+	degraded := v1helpers.FindStatusCondition(co.Status.Conditions, openshiftv1.OperatorDegraded)
+	g.Expect(degraded).ToNot(BeNil())
+	g.Expect(degraded.Status).To(Equal(openshiftv1.ConditionTrue))
+}
+
+// TestRunDrainsWorkers tests that Run waits for in-flight syncs to finish
+// before returning once the stop channel is closed.
+func TestRunDrainsWorkers(t *testing.T) {
+	g := NewWithT(t)
+
+	stopCh := make(chan struct{})
+	optr := newFakeOperator(nil, nil, stopCh)
+	optr.options.DrainTimeout = 5 * time.Second
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	optr.syncHandler = func(context.Context, workKey) error {
+		close(started)
+		<-release
+		return nil
+	}
+	optr.queue.Add(optr.workKey(syncScopeFull))
+
+	done := make(chan struct{})
+	go func() {
+		optr.Run(1, stopCh)
+		close(done)
+	}()
+
+	g.Eventually(started, 5*time.Second).Should(BeClosed())
+	close(stopCh)
+	g.Consistently(done, 50*time.Millisecond).ShouldNot(BeClosed(), "expected Run to wait for the in-flight sync")
+	close(release)
+	g.Eventually(done, 5*time.Second).Should(BeClosed())
+}
+
+// TestProcessNextWorkItemShuttingDown tests that the keys still queued once
+// shutdown started are dropped without being synced.
+func TestProcessNextWorkItemShuttingDown(t *testing.T) {
+	g := NewWithT(t)
+
+	optr := newFakeOperator(nil, nil, make(<-chan struct{}))
+	synced := false
+	optr.syncHandler = func(context.Context, workKey) error {
+		synced = true
+		return nil
+	}
+	optr.queue.Add(optr.workKey(syncScopeFull))
+	optr.queue.ShutDown()
+
+	g.Expect(optr.processNextWorkItem(context.Background())).To(BeTrue())
+	g.Expect(synced).To(BeFalse(), "expected the queued key to be dropped")
+	g.Expect(optr.processNextWorkItem(context.Background())).To(BeFalse())
+}
+
+func TestRunCacheSyncTimeout(t *testing.T) {
+	g := NewWithT(t)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	optr := newFakeOperator(nil, nil, stopCh)
+	optr.cacheSyncTimeout = 200 * time.Millisecond
+	var proxySynced int32
+	optr.proxyListerSynced = func() bool { return atomic.LoadInt32(&proxySynced) == 1 }
+
+	done := make(chan struct{})
+	go func() {
+		optr.Run(1, stopCh)
+		close(done)
+	}()
+
+	getDegraded := func() *openshiftv1.ClusterOperatorStatusCondition {
+		co, err := optr.getClusterOperator(context.Background())
+		if err != nil {
+			return nil
+		}
+		return v1helpers.FindStatusCondition(co.Status.Conditions, openshiftv1.OperatorDegraded)
+	}
+	g.Eventually(getDegraded, 5*time.Second).ShouldNot(BeNil())
+	degraded := getDegraded()
+	g.Expect(degraded.Status).To(Equal(openshiftv1.ConditionTrue))
+	g.Expect(degraded.Message).To(ContainSubstring("proxies"))
+	g.Expect(degraded.Message).ToNot(ContainSubstring("deployments"))
+
+	// Run keeps waiting for the caches and starts once they synced.
+	g.Expect(done).ToNot(BeClosed())
+	g.Expect(optr.health.ready()).To(BeFalse())
+	atomic.StoreInt32(&proxySynced, 1)
+	g.Eventually(optr.health.ready, 5*time.Second).Should(BeTrue())
+	g.Expect(done).ToNot(BeClosed())
+}
+
+func TestRunResync(t *testing.T) {
+	g := NewWithT(t)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	optr := newFakeOperator(nil, nil, stopCh)
+	optr.options.ResyncInterval = 100 * time.Millisecond
+
+	synced := make(chan workKey, 10)
+	optr.syncHandler = func(_ context.Context, key workKey) error {
+		select {
+		case synced <- key:
+		default:
+		}
+		return nil
+	}
+	go optr.Run(1, stopCh)
+
+	// No event is ever received, every sync comes from the periodic resync.
+	for i := 0; i < 2; i++ {
+		g.Eventually(synced, 5*time.Second).Should(Receive(Equal(optr.workKey(syncScopeFull))))
+	}
+}
+
+func TestEnqueueDebounce(t *testing.T) {
+	g := NewWithT(t)
+
+	stopCh := make(<-chan struct{})
+	optr := newFakeOperator(nil, nil, stopCh)
+	optr.options.SyncDebounce = 200 * time.Millisecond
+
+	for i := 0; i < 5; i++ {
+		optr.enqueue(optr.workKey(syncScopeFull))
+	}
+	g.Expect(optr.queue.Len()).To(BeZero(), "expected the sync to wait for the debounce window")
+	g.Eventually(optr.queue.Len, 2*time.Second).Should(Equal(1))
+	g.Consistently(optr.queue.Len, 500*time.Millisecond).Should(Equal(1))
+}
+
+// TestSyncWebhooksScope tests that a sync scoped to the webhooks leaves the operands alone.
+func TestSyncWebhooksScope(t *testing.T) {
+	g := NewWithT(t)
+
+	infra := &openshiftv1.Infrastructure{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster"},
+		Status:     openshiftv1.InfrastructureStatus{Platform: openshiftv1.AWSPlatformType},
+	}
+	proxy := &openshiftv1.Proxy{ObjectMeta: metav1.ObjectMeta{Name: "cluster"}}
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	optr := newFakeOperator(nil, []runtime.Object{infra, proxy}, stopCh)
+
+	g.Expect(optr.sync(context.Background(), optr.workKey(syncScopeWebhooks))).To(Succeed())
+
+	var created []string
+	for _, action := range optr.kubeClient.(*fakekube.Clientset).Actions() {
+		if action.GetVerb() == "create" {
+			created = append(created, action.GetResource().Resource)
+		}
+	}
+	g.Expect(created).To(ConsistOf("validatingwebhookconfigurations", "mutatingwebhookconfigurations"))
+}
+
+func TestWorkKeyScopes(t *testing.T) {
+	g := NewWithT(t)
+
+	optr := newFakeOperator(nil, nil, make(<-chan struct{}))
+	optr.options.SyncDebounce = 0
+
+	optr.enqueue(optr.workKey(syncScopeFull))
+	optr.enqueue(optr.workKey(syncScopeWebhooks))
+	optr.enqueue(optr.workKey(syncScopeWebhooks))
+	g.Expect(optr.queue.Len()).To(Equal(2), "expected keys to be coalesced per scope only")
+}
+
+// TestOperatorSyncPaused tests that the paused annotation on the ClusterOperator
+// stops the sync before anything is applied.
+func TestOperatorSyncPaused(t *testing.T) {
+	g := NewWithT(t)
+
+	co := &openshiftv1.ClusterOperator{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        clusterOperatorName,
+			Annotations: map[string]string{pausedAnnotation: ""},
+		},
+	}
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	optr := newFakeOperator(nil, []runtime.Object{co}, stopCh)
+	recorder := optr.eventRecorder.(*record.FakeRecorder)
+
+	// No Infrastructure exists, so any sync that is not paused fails.
+	for i := 0; i < 2; i++ {
+		g.Expect(optr.sync(context.Background(), optr.workKey(syncScopeFull))).To(Succeed())
+	}
+	g.Expect(optr.kubeClient.(*fakekube.Clientset).Actions()).To(BeEmpty())
+	g.Expect(recorder.Events).To(HaveLen(1), "expected a single event while paused")
+
+	co, err := optr.getClusterOperator(context.Background())
+	g.Expect(err).ToNot(HaveOccurred())
+	progressing := v1helpers.FindStatusCondition(co.Status.Conditions, openshiftv1.OperatorProgressing)
+	g.Expect(progressing).ToNot(BeNil())
+	g.Expect(progressing.Status).To(Equal(openshiftv1.ConditionFalse))
+	g.Expect(progressing.Reason).To(Equal(string(ReasonPaused)))
+
+	// Removing the annotation resumes reconciliation.
+	co.Annotations = nil
+	_, err = optr.osClient.ConfigV1().ClusterOperators().Update(context.Background(), co, metav1.UpdateOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(optr.sync(context.Background(), optr.workKey(syncScopeFull))).ToNot(Succeed())
+}
+
+// TestEventHandlerMachineWebhook tests that events of either machine-api
+// webhook configuration enqueue a webhooks sync.
+func TestEventHandlerMachineWebhook(t *testing.T) {
+	g := NewWithT(t)
+
+	optr := newFakeOperator(nil, nil, make(<-chan struct{}))
+	optr.options.SyncDebounce = 0
+	handler := optr.eventHandlerSingleton(isMachineWebhook, syncScopeWebhooks)
+
+	objectMeta := func(name string) metav1.ObjectMeta { return metav1.ObjectMeta{Name: name} }
+	for _, obj := range []interface{}{
+		&admissionregistrationv1.ValidatingWebhookConfiguration{ObjectMeta: objectMeta("other")},
+		&admissionregistrationv1.MutatingWebhookConfiguration{ObjectMeta: objectMeta("other")},
+	} {
+		handler.OnAdd(obj)
+	}
+	g.Expect(optr.queue.Len()).To(BeZero())
+
+	for _, event := range []func(){
+		func() {
+			handler.OnAdd(&admissionregistrationv1.ValidatingWebhookConfiguration{ObjectMeta: objectMeta("machine-api")})
+		},
+		func() {
+			handler.OnAdd(&admissionregistrationv1.MutatingWebhookConfiguration{ObjectMeta: objectMeta("machine-api")})
+		},
+		func() {
+			handler.OnDelete(cache.DeletedFinalStateUnknown{
+				Key: "machine-api",
+				Obj: &admissionregistrationv1.ValidatingWebhookConfiguration{ObjectMeta: objectMeta("machine-api")},
+			})
+		},
+	} {
+		event()
+		g.Expect(optr.queue.Len()).To(Equal(1))
+		key, _ := optr.queue.Get()
+		g.Expect(key).To(Equal(optr.workKey(syncScopeWebhooks)))
+		optr.queue.Done(key)
+	}
+}
+
+func TestEventHandlerForceSync(t *testing.T) {
+	g := NewWithT(t)
+
+	optr := newFakeOperator(nil, nil, make(<-chan struct{}))
+	optr.options.SyncDebounce = time.Hour
+	handler := optr.eventHandlerForceSync(isMachineAPIClusterOperator)
+
+	withNonce := func(name, nonce string) *openshiftv1.ClusterOperator {
+		co := &openshiftv1.ClusterOperator{ObjectMeta: metav1.ObjectMeta{Name: name}}
+		if nonce != "" {
+			co.Annotations = map[string]string{forceSyncAnnotation: nonce}
+		}
+		return co
+	}
+
+	// Neither an unchanged nonce nor another ClusterOperator triggers a sync.
+	handler.OnUpdate(withNonce(clusterOperatorName, "1"), withNonce(clusterOperatorName, "1"))
+	handler.OnUpdate(withNonce("other", ""), withNonce("other", "1"))
+	g.Expect(optr.queue.Len()).To(BeZero())
+
+	// A new nonce enqueues a full sync right away, ignoring the debounce window.
+	handler.OnUpdate(withNonce(clusterOperatorName, "1"), withNonce(clusterOperatorName, "2"))
+	g.Expect(optr.queue.Len()).To(Equal(1))
+	key, _ := optr.queue.Get()
+	g.Expect(key).To(Equal(optr.workKey(syncScopeFull)))
+	optr.queue.Done(key)
+
+	// Standalone, the annotation is set on the status configmap instead.
+	handler = optr.eventHandlerForceSync(isStandaloneStatusConfigMap)
+	cmWithNonce := func(name, nonce string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Annotations: map[string]string{forceSyncAnnotation: nonce},
+		}}
+	}
+	handler.OnUpdate(cmWithNonce("other", "1"), cmWithNonce("other", "2"))
+	g.Expect(optr.queue.Len()).To(BeZero())
+	handler.OnUpdate(cmWithNonce(standaloneStatusConfigMapName, "1"), cmWithNonce(standaloneStatusConfigMapName, "2"))
+	g.Expect(optr.queue.Len()).To(Equal(1))
+}
+
+func TestSleepJitter(t *testing.T) {
+	g := NewWithT(t)
+
+	start := time.Now()
+	sleepJitter(50*time.Millisecond, make(chan struct{}))
+	g.Expect(time.Since(start)).To(BeNumerically("<", time.Second))
+
+	// A closed stopCh cuts the delay short.
+	stopCh := make(chan struct{})
+	close(stopCh)
+	start = time.Now()
+	sleepJitter(time.Hour, stopCh)
+	g.Expect(time.Since(start)).To(BeNumerically("<", time.Second))
+}
+
+func TestRunSerializesSyncs(t *testing.T) {
+	g := NewWithT(t)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	optr := newFakeOperator(nil, nil, stopCh)
+
+	started := make(chan workKey, 2)
+	release := make(chan struct{})
+	optr.syncHandler = func(_ context.Context, key workKey) error {
+		started <- key
+		<-release
+		return nil
+	}
+	optr.queue.Add(optr.workKey(syncScopeFull))
+	optr.queue.Add(optr.workKey(syncScopeWebhooks))
+	go optr.Run(2, stopCh)
+
+	g.Eventually(started, 5*time.Second).Should(Receive())
+	// The other key waits for the in-flight sync, although a worker is free.
+	g.Consistently(started, 50*time.Millisecond).ShouldNot(Receive(), "expected the keys of different scopes not to be synced concurrently")
+	close(release)
+	g.Eventually(started, 5*time.Second).Should(Receive())
+}
@@ -0,0 +1,81 @@
+package operator
+
+import (
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Options tune the behaviour of the operator and the operands it renders.
+// DefaultOptions returns the defaults, which the zero value does not match.
+type Options struct {
+	// DrainTimeout bounds how long Run waits for in-flight syncs on shutdown.
+	DrainTimeout time.Duration
+	// Health receives the readiness of the operator, for the caller to serve
+	// it with ServeHealth. Optional.
+	Health *Health
+	// MaxRetries is the number of times a key is retried before it is dropped out of the queue.
+	MaxRetries int
+	// BaseBackoff and MaxBackoff bound the delay between retries of a failed sync.
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	// ResyncInterval is how often a full sync is enqueued without any watch event, disabled when zero.
+	ResyncInterval time.Duration
+	// SyncDebounce is the window in which enqueued syncs are coalesced into one.
+	SyncDebounce time.Duration
+	// RolloutPollInterval and RolloutTimeout control how operand rollouts are waited for.
+	RolloutPollInterval time.Duration
+	RolloutTimeout      time.Duration
+
+	// RequireImageDigests rejects operand images which are not pinned by digest.
+	RequireImageDigests bool
+	// DefaultMissingImages fills the images missing from the images files with
+	// built-in, unpinned defaults. When unset, the default, an image required by
+	// the platform but missing from the files fails the sync.
+	DefaultMissingImages bool
+
+	// ControllerReplicas is the number of replicas of the machine-api-controllers Deployment.
+	ControllerReplicas int32
+	// ControllerTolerations are added to the tolerations of the machine-api-controllers pods.
+	ControllerTolerations []corev1.Toleration
+	// ControllerTopologySpreadConstraints are set on the machine-api-controllers pods.
+	ControllerTopologySpreadConstraints []corev1.TopologySpreadConstraint
+	// ControllerExtraVolumes and ControllerExtraVolumeMounts are added to the
+	// machine-api-controllers pods and their machine-controller container.
+	ControllerExtraVolumes      []corev1.Volume
+	ControllerExtraVolumeMounts []corev1.VolumeMount
+	// ControllerInitContainers are run in the machine-api-controllers pods before the controllers.
+	ControllerInitContainers []corev1.Container
+	// ImagePullPolicy is set on the containers of the machine-api-controllers pods.
+	ImagePullPolicy corev1.PullPolicy
+	// Resources replace the default resource requirements of the operand containers.
+	Resources map[string]corev1.ResourceRequirements
+	// ControllersMinAvailable is the minAvailable of the controllers PodDisruptionBudget,
+	// derived from their replicas when negative.
+	ControllersMinAvailable int
+
+	// AllowedNamespaces are the namespaces operands may be written into.
+	AllowedNamespaces []string
+	// Standalone reports status into a configmap instead of the ClusterOperator
+	// for running without the cluster-version-operator.
+	Standalone bool
+	// Observe reports the managed objects which drifted from the desired state
+	// instead of applying them.
+	Observe bool
+}
+
+// DefaultOptions returns the options the operator runs with unless told otherwise.
+func DefaultOptions() Options {
+	return Options{
+		DrainTimeout:            DefaultDrainTimeout,
+		MaxRetries:              DefaultMaxRetries,
+		BaseBackoff:             DefaultBaseBackoff,
+		MaxBackoff:              DefaultMaxBackoff,
+		ResyncInterval:          DefaultResyncInterval,
+		SyncDebounce:            DefaultSyncDebounce,
+		RolloutPollInterval:     DefaultRolloutPollInterval,
+		RolloutTimeout:          DefaultRolloutTimeout,
+		ControllerReplicas:      DefaultControllerReplicas,
+		ControllersMinAvailable: DefaultControllersMinAvailable,
+	}
+}
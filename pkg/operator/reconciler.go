@@ -0,0 +1,46 @@
+package operator
+
+import "context"
+
+// Reconciler is a single step of a sync, applying one group of operands.
+type Reconciler interface {
+	// Name identifies the step in logs and errors.
+	Name() string
+	// Reconcile applies the operands of the step for the given config.
+	Reconcile(ctx context.Context, config *OperatorConfig) error
+}
+
+// reconcilerFunc adapts a function into a Reconciler.
+type reconcilerFunc struct {
+	name      string
+	reconcile func(ctx context.Context, config *OperatorConfig) error
+}
+
+func (r reconcilerFunc) Name() string {
+	return r.name
+}
+
+func (r reconcilerFunc) Reconcile(ctx context.Context, config *OperatorConfig) error {
+	return r.reconcile(ctx, config)
+}
+
+// defaultReconcilers returns the steps of a full sync, in the order they run.
+// The webhook configurations come first so that they are in place before the
+// controllers serving them roll out. A missing credentials secret is reported
+// without holding back the controllers, which pick it up once it is created.
+func (optr *Operator) defaultReconcilers() []Reconciler {
+	return []Reconciler{
+		reconcilerFunc{
+			name:      "webhooks",
+			reconcile: optr.syncWebhookConfiguration,
+		},
+		reconcilerFunc{
+			name:      "provider-credentials",
+			reconcile: optr.checkProviderCredentials,
+		},
+		reconcilerFunc{
+			name:      "machine-api-controllers",
+			reconcile: optr.syncClusterAPIController,
+		},
+	}
+}
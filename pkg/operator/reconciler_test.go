@@ -0,0 +1,55 @@
+package operator
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestSyncAllRunsReconcilers(t *testing.T) {
+	g := NewWithT(t)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	optr := newFakeOperator(nil, nil, stopCh)
+
+	var ran []string
+	step := func(name string, err error) Reconciler {
+		return reconcilerFunc{
+			name: name,
+			reconcile: func(context.Context, *OperatorConfig) error {
+				ran = append(ran, name)
+				return err
+			},
+		}
+	}
+	optr.reconcilers = []Reconciler{
+		step("first", nil),
+		step("second", errors.New("second failed")),
+		step("third", nil),
+	}
+
+	config := &OperatorConfig{
+		TargetNamespace: targetNamespace,
+		Controllers:     Controllers{Provider: "provider-image"},
+	}
+	err := optr.syncAll(context.Background(), config)
+	g.Expect(err).To(MatchError(ContainSubstring("second: second failed")))
+	g.Expect(ran).To(Equal([]string{"first", "second", "third"}))
+
+	optr.reconcilers = []Reconciler{step("fourth", nil)}
+	g.Expect(optr.syncAll(context.Background(), config)).To(Succeed())
+}
+
+func TestDefaultReconcilers(t *testing.T) {
+	g := NewWithT(t)
+
+	optr := newFakeOperator(nil, nil, make(<-chan struct{}))
+	var names []string
+	for _, r := range optr.defaultReconcilers() {
+		names = append(names, r.Name())
+	}
+	g.Expect(names).To(Equal([]string{"webhooks", "provider-credentials", "machine-api-controllers"}))
+}
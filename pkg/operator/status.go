@@ -2,6 +2,8 @@ package operator
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"reflect"
 	"strings"
@@ -10,8 +12,9 @@ import (
 	"github.com/openshift/library-go/pkg/config/clusteroperator/v1helpers"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
-	"k8s.io/apimachinery/pkg/api/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/klog/v2"
 )
 
@@ -25,10 +28,23 @@ const (
 	ReasonInitializing StatusReason = "Initializing"
 	ReasonSyncing      StatusReason = "SyncingResources"
 	ReasonSyncFailed   StatusReason = "SyncingFailed"
+	ReasonPaused       StatusReason = "Paused"
+	ReasonObserving    StatusReason = "Observing"
+
+	ReasonConfigNotFound      StatusReason = "ConfigNotFound"
+	ReasonInvalidProvider     StatusReason = "InvalidProvider"
+	ReasonImagesFileMissing   StatusReason = "ImagesFileMissing"
+	ReasonNamespaceNotAllowed StatusReason = "NamespaceNotAllowed"
 )
 
 const (
 	clusterOperatorName = "machine-api"
+
+	// standaloneStatusConfigMapName is the configmap in the operator namespace
+	// the status is reported into instead of the ClusterOperator when running
+	// standalone, under the standaloneStatusKey key.
+	standaloneStatusConfigMapName = "machine-api-operator-status"
+	standaloneStatusKey           = "status"
 )
 
 var (
@@ -43,16 +59,16 @@ var (
 // statusProgressing sets the Progressing condition to True, with the given
 // reason and message, and sets the upgradeable condition to True.  It does not
 // modify any existing Available or Degraded conditions.
-func (optr *Operator) statusProgressing() error {
+func (optr *Operator) statusProgressing(ctx context.Context) error {
 	desiredVersions := optr.operandVersions
-	currentVersions, err := optr.getCurrentVersions()
+	currentVersions, err := optr.getCurrentVersions(ctx)
 	if err != nil {
 		klog.Errorf("Error getting operator current versions: %v", err)
 		return err
 	}
 	var isProgressing osconfigv1.ConditionStatus
 
-	co, err := optr.getOrCreateClusterOperator()
+	co, err := optr.getOrCreateClusterOperator(ctx)
 	if err != nil {
 		klog.Errorf("Failed to get or create Cluster Operator: %v", err)
 		return err
@@ -76,12 +92,13 @@ func (optr *Operator) statusProgressing() error {
 		operatorUpgradeable,
 	}
 
-	return optr.syncStatus(co, conds)
+	return optr.syncStatus(ctx, co, conds)
 }
 
 // statusAvailable sets the Available condition to True, with the given reason
 // and message, and sets both the Progressing and Degraded conditions to False.
-func (optr *Operator) statusAvailable() error {
+// The state of the synced operands is reported in the status extension.
+func (optr *Operator) statusAvailable(ctx context.Context, config *OperatorConfig) error {
 	conds := []osconfigv1.ClusterOperatorStatusCondition{
 		newClusterOperatorStatusCondition(osconfigv1.OperatorAvailable, osconfigv1.ConditionTrue, string(ReasonAsExpected),
 			fmt.Sprintf("Cluster Machine API Operator is available at %s", optr.printOperandVersions())),
@@ -90,23 +107,86 @@ func (optr *Operator) statusAvailable() error {
 		operatorUpgradeable,
 	}
 
-	co, err := optr.getOrCreateClusterOperator()
+	co, err := optr.getOrCreateClusterOperator(ctx)
 	if err != nil {
 		return err
 	}
+	existing := co.Status.DeepCopy()
 
 	// 	important: we only write the version field if we report available at the present level
 	co.Status.Versions = optr.operandVersions
+
+	extension, err := json.Marshal(optr.newOperatorStatus(config))
+	if err != nil {
+		return fmt.Errorf("failed to encode status extension: %w", err)
+	}
+	co.Status.Extension = runtime.RawExtension{Raw: extension}
 	klog.V(2).Info("Syncing status: available")
-	return optr.syncStatus(co, conds)
+	return optr.syncStatusFrom(ctx, existing, co, conds)
+}
+
+// statusPaused sets the Progressing condition to False with the Paused reason.
+// It does not modify any existing Available or Degraded conditions.
+func (optr *Operator) statusPaused(ctx context.Context, co *osconfigv1.ClusterOperator) error {
+	message := fmt.Sprintf("Reconciliation is paused by the %s annotation", pausedAnnotation)
+	// Only record an event when pausing, not on every sync while paused.
+	if progressing := v1helpers.FindStatusCondition(co.Status.Conditions, osconfigv1.OperatorProgressing); progressing == nil ||
+		progressing.Reason != string(ReasonPaused) {
+		optr.eventRecorder.Eventf(optr.eventObject(co), v1.EventTypeNormal, "Paused", message)
+	}
+
+	conds := []osconfigv1.ClusterOperatorStatusCondition{
+		newClusterOperatorStatusCondition(osconfigv1.OperatorProgressing, osconfigv1.ConditionFalse,
+			string(ReasonPaused), message),
+	}
+	klog.V(2).Info("Syncing status: paused")
+	return optr.syncStatus(ctx, co, conds)
+}
+
+// statusObserving sets the Progressing condition to False with the Observing
+// reason and a message listing the drifted operands. It does not modify any
+// existing Available or Degraded conditions.
+func (optr *Operator) statusObserving(ctx context.Context, drifted []string) error {
+	co, err := optr.getOrCreateClusterOperator(ctx)
+	if err != nil {
+		return err
+	}
+
+	message := "Observing, no operand drifted from the desired state"
+	if len(drifted) > 0 {
+		message = fmt.Sprintf("Observing, operands drifted from the desired state: %s", strings.Join(drifted, ", "))
+	}
+	conds := []osconfigv1.ClusterOperatorStatusCondition{
+		newClusterOperatorStatusCondition(osconfigv1.OperatorProgressing, osconfigv1.ConditionFalse,
+			string(ReasonObserving), message),
+	}
+	klog.V(2).Info("Syncing status: observing")
+	return optr.syncStatus(ctx, co, conds)
 }
 
-// statusDegraded sets the Degraded condition to True, with the given reason and
-// message, and sets the upgradeable condition.  It does not modify any existing
-// Available or Progressing conditions.
-func (optr *Operator) statusDegraded(error string) error {
+// degradedReason returns the reason of the Degraded condition for err.
+func degradedReason(err error) StatusReason {
+	switch {
+	case errors.Is(err, ErrConfigNotFound):
+		return ReasonConfigNotFound
+	case errors.Is(err, ErrInvalidProvider):
+		return ReasonInvalidProvider
+	case errors.Is(err, ErrImagesFileMissing):
+		return ReasonImagesFileMissing
+	case errors.Is(err, ErrNamespaceNotAllowed):
+		return ReasonNamespaceNotAllowed
+	default:
+		return ReasonSyncFailed
+	}
+}
+
+// statusDegraded sets the Degraded condition to True, with a reason and message
+// derived from the given error, and sets the upgradeable condition.  It does not
+// modify any existing Available or Progressing conditions.
+func (optr *Operator) statusDegraded(ctx context.Context, syncErr error) error {
+	syncErrMsg := syncErr.Error()
 	desiredVersions := optr.operandVersions
-	currentVersions, err := optr.getCurrentVersions()
+	currentVersions, err := optr.getCurrentVersions(ctx)
 	if err != nil {
 		klog.Errorf("Error getting current versions: %v", err)
 		return err
@@ -114,24 +194,111 @@ func (optr *Operator) statusDegraded(error string) error {
 
 	var message string
 	if !reflect.DeepEqual(desiredVersions, currentVersions) {
-		message = fmt.Sprintf("Failed when progressing towards %s because %s", optr.printOperandVersions(), error)
+		message = fmt.Sprintf("Failed when progressing towards %s because %s", optr.printOperandVersions(), syncErrMsg)
 	} else {
-		message = fmt.Sprintf("Failed to resync for %s because %s", optr.printOperandVersions(), error)
+		message = fmt.Sprintf("Failed to resync for %s because %s", optr.printOperandVersions(), syncErrMsg)
 	}
 
 	conds := []osconfigv1.ClusterOperatorStatusCondition{
 		newClusterOperatorStatusCondition(osconfigv1.OperatorDegraded, osconfigv1.ConditionTrue,
-			string(ReasonSyncFailed), message),
+			string(degradedReason(syncErr)), message),
 		operatorUpgradeable,
 	}
 
-	co, err := optr.getOrCreateClusterOperator()
+	co, err := optr.getOrCreateClusterOperator(ctx)
 	if err != nil {
 		return err
 	}
-	optr.eventRecorder.Eventf(co, v1.EventTypeWarning, "Status degraded", error)
+	// Only record an event when the Degraded condition actually changes, so retries
+	// failing with the same error don't flood the events.
+	if degraded := v1helpers.FindStatusCondition(co.Status.Conditions, osconfigv1.OperatorDegraded); degraded == nil ||
+		degraded.Status != osconfigv1.ConditionTrue || degraded.Message != message {
+		optr.eventRecorder.Eventf(optr.eventObject(co), v1.EventTypeWarning, "Status degraded", syncErrMsg)
+	} else {
+		klog.V(4).Infof("Degraded since %v with the same message, not recording an event", degraded.LastTransitionTime)
+	}
 	klog.V(2).Info("Syncing status: degraded")
-	return optr.syncStatus(co, conds)
+	return optr.syncStatus(ctx, co, conds)
+}
+
+// operatorStatus is reported in the ClusterOperator status extension after
+// every successful sync, so what the operator reconciled can be inspected
+// from a single object. It only holds state derived from the cluster, so that
+// a sync which changes nothing leaves the status untouched.
+type operatorStatus struct {
+	Platform osconfigv1.PlatformType `json:"platform"`
+	Images   Controllers             `json:"images"`
+	Operands []operandStatus         `json:"operands,omitempty"`
+}
+
+// operandStatus describes the rollout state of a workload managed by the operator.
+type operandStatus struct {
+	Kind          string `json:"kind"`
+	Namespace     string `json:"namespace"`
+	Name          string `json:"name"`
+	Replicas      int32  `json:"replicas"`
+	ReadyReplicas int32  `json:"readyReplicas"`
+}
+
+func (optr *Operator) newOperatorStatus(config *OperatorConfig) operatorStatus {
+	status := operatorStatus{
+		Platform: config.PlatformType,
+		Images:   config.Controllers,
+	}
+	if config.Controllers.Provider == clusterAPIControllerNoOp {
+		return status
+	}
+
+	if d, err := optr.deployLister.Deployments(config.TargetNamespace).Get("machine-api-controllers"); err == nil {
+		status.Operands = append(status.Operands, operandStatus{
+			Kind:          "Deployment",
+			Namespace:     d.Namespace,
+			Name:          d.Name,
+			Replicas:      d.Status.Replicas,
+			ReadyReplicas: d.Status.ReadyReplicas,
+		})
+	} else {
+		klog.V(4).Infof("Not reporting machine-api-controllers in status: %v", err)
+	}
+
+	if config.Controllers.TerminationHandler == clusterAPIControllerNoOp {
+		return status
+	}
+	if ds, err := optr.daemonsetLister.DaemonSets(config.TargetNamespace).Get(machineAPITerminationHandler); err == nil {
+		status.Operands = append(status.Operands, operandStatus{
+			Kind:          "DaemonSet",
+			Namespace:     ds.Namespace,
+			Name:          ds.Name,
+			Replicas:      ds.Status.DesiredNumberScheduled,
+			ReadyReplicas: ds.Status.NumberReady,
+		})
+	} else {
+		klog.V(4).Infof("Not reporting %s in status: %v", machineAPITerminationHandler, err)
+	}
+	return status
+}
+
+// eventf records an event against the machine-api ClusterOperator so that
+// progress of the managed resources shows up when describing it.
+func (optr *Operator) eventf(ctx context.Context, eventType, reason, messageFmt string, args ...interface{}) {
+	var co *osconfigv1.ClusterOperator
+	if !optr.options.Standalone {
+		var err error
+		if co, err = optr.getClusterOperator(ctx); err != nil {
+			klog.V(4).Infof("Failed to get ClusterOperator for event reference, using name only: %v", err)
+			co = &osconfigv1.ClusterOperator{ObjectMeta: metav1.ObjectMeta{Name: clusterOperatorName}}
+		}
+	}
+	optr.eventRecorder.Eventf(optr.eventObject(co), eventType, reason, messageFmt, args...)
+}
+
+// eventObject returns the object the events of the operator are recorded
+// against: co, or the status configmap when running standalone.
+func (optr *Operator) eventObject(co *osconfigv1.ClusterOperator) runtime.Object {
+	if optr.options.Standalone {
+		return &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: standaloneStatusConfigMapName, Namespace: optr.namespace}}
+	}
+	return co
 }
 
 func newClusterOperatorStatusCondition(conditionType osconfigv1.ClusterStatusConditionType,
@@ -147,12 +314,23 @@ func newClusterOperatorStatusCondition(conditionType osconfigv1.ClusterStatusCon
 }
 
 //syncStatus applies the new condition to the mao ClusterOperator object.
-func (optr *Operator) syncStatus(co *osconfigv1.ClusterOperator, conds []osconfigv1.ClusterOperatorStatusCondition) error {
+func (optr *Operator) syncStatus(ctx context.Context, co *osconfigv1.ClusterOperator, conds []osconfigv1.ClusterOperatorStatusCondition) error {
+	return optr.syncStatusFrom(ctx, co.Status.DeepCopy(), co, conds)
+}
+
+// syncStatusFrom applies the conditions to co and writes its status, unless
+// the result is still equal to existing, the status co was read with.
+func (optr *Operator) syncStatusFrom(ctx context.Context, existing *osconfigv1.ClusterOperatorStatus, co *osconfigv1.ClusterOperator,
+	conds []osconfigv1.ClusterOperatorStatusCondition) error {
 	for _, c := range conds {
 		v1helpers.SetStatusCondition(&co.Status.Conditions, c)
 	}
 
-	_, err := optr.osClient.ConfigV1().ClusterOperators().UpdateStatus(context.Background(), co, metav1.UpdateOptions{})
+	if equality.Semantic.DeepEqual(*existing, co.Status) {
+		klog.V(4).Info("ClusterOperator status unchanged, skipping update")
+		return nil
+	}
+	_, err := optr.updateClusterOperatorStatus(ctx, co)
 	return err
 }
 
@@ -248,12 +426,12 @@ func (optr *Operator) defaultClusterOperator() *osconfigv1.ClusterOperator {
 
 // updateRelatedObjects updates the ClusterOperator's related objects field if
 // necessary and returns the updated ClusterOperator object.
-func (optr *Operator) updateRelatedObjects(co *osconfigv1.ClusterOperator) (*osconfigv1.ClusterOperator, error) {
+func (optr *Operator) updateRelatedObjects(ctx context.Context, co *osconfigv1.ClusterOperator) (*osconfigv1.ClusterOperator, error) {
 	relatedObjects := optr.relatedObjects()
 
 	if !equality.Semantic.DeepEqual(co.Status.RelatedObjects, relatedObjects) {
 		co.Status.RelatedObjects = relatedObjects
-		return optr.osClient.ConfigV1().ClusterOperators().UpdateStatus(context.Background(), co, metav1.UpdateOptions{})
+		return optr.updateClusterOperatorStatus(ctx, co)
 	}
 
 	return co, nil
@@ -262,7 +440,7 @@ func (optr *Operator) updateRelatedObjects(co *osconfigv1.ClusterOperator) (*osc
 // setMissingStatusConditions checks that the given ClusterOperator has a value
 // for each of the default status conditions, and sets the default value for any
 // that are missing.
-func (optr *Operator) setMissingStatusConditions(co *osconfigv1.ClusterOperator) (*osconfigv1.ClusterOperator, error) {
+func (optr *Operator) setMissingStatusConditions(ctx context.Context, co *osconfigv1.ClusterOperator) (*osconfigv1.ClusterOperator, error) {
 	var modified bool
 
 	for _, c := range optr.defaultStatusConditions() {
@@ -273,57 +451,133 @@ func (optr *Operator) setMissingStatusConditions(co *osconfigv1.ClusterOperator)
 	}
 
 	if modified {
-		return optr.osClient.ConfigV1().ClusterOperators().UpdateStatus(context.Background(), co, metav1.UpdateOptions{})
+		return optr.updateClusterOperatorStatus(ctx, co)
 	}
 
 	return co, nil
 }
 
 // getClusterOperator returns the current ClusterOperator.
-func (optr *Operator) getClusterOperator() (*osconfigv1.ClusterOperator, error) {
+func (optr *Operator) getClusterOperator(ctx context.Context) (*osconfigv1.ClusterOperator, error) {
+	if optr.options.Standalone {
+		cm, err := optr.kubeClient.CoreV1().ConfigMaps(optr.namespace).
+			Get(ctx, standaloneStatusConfigMapName, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		return clusterOperatorFromConfigMap(cm)
+	}
 	return optr.osClient.ConfigV1().ClusterOperators().
-		Get(context.Background(), clusterOperatorName, metav1.GetOptions{})
+		Get(ctx, clusterOperatorName, metav1.GetOptions{})
 }
 
 // createClusterOperator creates the ClusterOperator and updates its status.
-func (optr *Operator) createClusterOperator() (*osconfigv1.ClusterOperator, error) {
+func (optr *Operator) createClusterOperator(ctx context.Context) (*osconfigv1.ClusterOperator, error) {
 	defaultCO := optr.defaultClusterOperator()
 
-	co, err := optr.osClient.ConfigV1().ClusterOperators().Create(context.Background(), defaultCO, metav1.CreateOptions{})
+	if optr.options.Standalone {
+		cm, err := optr.statusConfigMap(defaultCO)
+		if err != nil {
+			return nil, err
+		}
+		cm, err = optr.kubeClient.CoreV1().ConfigMaps(optr.namespace).Create(ctx, cm, metav1.CreateOptions{})
+		if err != nil {
+			return nil, err
+		}
+		return clusterOperatorFromConfigMap(cm)
+	}
+
+	co, err := optr.osClient.ConfigV1().ClusterOperators().Create(ctx, defaultCO, metav1.CreateOptions{})
 	if err != nil {
 		return nil, err
 	}
 
 	co.Status = defaultCO.Status
 
-	return optr.osClient.ConfigV1().ClusterOperators().UpdateStatus(context.Background(), co, metav1.UpdateOptions{})
+	return optr.updateClusterOperatorStatus(ctx, co)
+}
+
+// updateClusterOperatorStatus writes the status of the given ClusterOperator.
+func (optr *Operator) updateClusterOperatorStatus(ctx context.Context, co *osconfigv1.ClusterOperator) (*osconfigv1.ClusterOperator, error) {
+	if optr.options.Standalone {
+		cm, err := optr.statusConfigMap(co)
+		if err != nil {
+			return nil, err
+		}
+		cm, err = optr.kubeClient.CoreV1().ConfigMaps(optr.namespace).Update(ctx, cm, metav1.UpdateOptions{})
+		if err != nil {
+			return nil, err
+		}
+		return clusterOperatorFromConfigMap(cm)
+	}
+	return optr.osClient.ConfigV1().ClusterOperators().UpdateStatus(ctx, co, metav1.UpdateOptions{})
+}
+
+// statusConfigMap returns the configmap holding the status of the given
+// ClusterOperator when running standalone. The annotations and resource version
+// of the ClusterOperator are carried over so that pausing, forcing a sync and
+// conflict detection work the same way as on the ClusterOperator itself.
+func (optr *Operator) statusConfigMap(co *osconfigv1.ClusterOperator) (*v1.ConfigMap, error) {
+	status, err := json.Marshal(co.Status)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal ClusterOperator status: %w", err)
+	}
+	return &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            standaloneStatusConfigMapName,
+			Namespace:       optr.namespace,
+			Annotations:     co.Annotations,
+			ResourceVersion: co.ResourceVersion,
+		},
+		Data: map[string]string{
+			standaloneStatusKey: string(status),
+		},
+	}, nil
+}
+
+// clusterOperatorFromConfigMap returns the ClusterOperator whose status is
+// held by the given standalone status configmap.
+func clusterOperatorFromConfigMap(cm *v1.ConfigMap) (*osconfigv1.ClusterOperator, error) {
+	co := &osconfigv1.ClusterOperator{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            clusterOperatorName,
+			Annotations:     cm.Annotations,
+			ResourceVersion: cm.ResourceVersion,
+		},
+	}
+	if status, ok := cm.Data[standaloneStatusKey]; ok {
+		if err := json.Unmarshal([]byte(status), &co.Status); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal status from configmap %s/%s: %w", cm.Namespace, cm.Name, err)
+		}
+	}
+	return co, nil
 }
 
 // getOrCreateClusterOperator fetches the current ClusterOperator or creates a
 // default one if not found -- ensuring the related objects list is current.
-func (optr *Operator) getOrCreateClusterOperator() (*osconfigv1.ClusterOperator, error) {
-	existing, err := optr.getClusterOperator()
+func (optr *Operator) getOrCreateClusterOperator(ctx context.Context) (*osconfigv1.ClusterOperator, error) {
+	existing, err := optr.getClusterOperator(ctx)
 
-	if errors.IsNotFound(err) {
+	if apierrors.IsNotFound(err) {
 		klog.Infof("ClusterOperator does not exist, creating a new one.")
-		return optr.createClusterOperator()
+		return optr.createClusterOperator(ctx)
 	}
 
 	if err != nil {
-		return nil, fmt.Errorf("failed to get clusterOperator %q: %v", clusterOperatorName, err)
+		return nil, fmt.Errorf("failed to get clusterOperator %q: %w", clusterOperatorName, err)
 	}
 
 	// Update any missing status conditions with their default value.
-	existing, err = optr.setMissingStatusConditions(existing)
+	existing, err = optr.setMissingStatusConditions(ctx, existing)
 	if err != nil {
-		return nil, fmt.Errorf("failed to set default conditions: %v", err)
+		return nil, fmt.Errorf("failed to set default conditions: %w", err)
 	}
 
-	return optr.updateRelatedObjects(existing)
+	return optr.updateRelatedObjects(ctx, existing)
 }
 
-func (optr *Operator) getCurrentVersions() ([]osconfigv1.OperandVersion, error) {
-	co, err := optr.getOrCreateClusterOperator()
+func (optr *Operator) getCurrentVersions(ctx context.Context) ([]osconfigv1.OperandVersion, error) {
+	co, err := optr.getOrCreateClusterOperator(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -2,6 +2,8 @@ package operator
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"testing"
 	"time"
 
@@ -9,8 +11,12 @@ import (
 	fakeconfigclientset "github.com/openshift/client-go/config/clientset/versioned/fake"
 	"github.com/openshift/library-go/pkg/config/clusteroperator/v1helpers"
 	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/record"
 )
 
@@ -92,9 +98,9 @@ func TestOperatorStatusProgressing(t *testing.T) {
 		co.Status.Versions = tc.desiredVersion
 		optr.osClient = fakeconfigclientset.NewSimpleClientset(co)
 
-		optr.statusProgressing()
+		optr.statusProgressing(context.Background())
 
-		gotCO, err := optr.getClusterOperator()
+		gotCO, err := optr.getClusterOperator(context.Background())
 		if err != nil {
 			t.Fatalf("Failed to fetch ClusterOperator: %v", err)
 		}
@@ -119,7 +125,7 @@ func TestOperatorStatusProgressing(t *testing.T) {
 			}
 		}
 
-		optr.statusProgressing()
+		optr.statusProgressing(context.Background())
 		gotCO, _ = optr.osClient.ConfigV1().ClusterOperators().Get(context.Background(), clusterOperatorName, metav1.GetOptions{})
 		var conditionAfterAnotherSync osconfigv1.ClusterOperatorStatusCondition
 		for _, coCondition := range gotCO.Status.Conditions {
@@ -327,7 +333,7 @@ func TestGetOrCreateClusterOperator(t *testing.T) {
 			namespace: namespace,
 		}
 
-		co, err := optr.getOrCreateClusterOperator()
+		co, err := optr.getOrCreateClusterOperator(context.Background())
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -351,3 +357,169 @@ func normalizeTransitionTimes(got, expected osconfigv1.ClusterOperatorStatus) {
 		expected.Conditions[i].LastTransitionTime = now
 	}
 }
+
+func TestOperatorStatusAvailableExtension(t *testing.T) {
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "machine-api-controllers",
+			Namespace: targetNamespace,
+		},
+		Status: appsv1.DeploymentStatus{
+			Replicas:      1,
+			ReadyReplicas: 1,
+		},
+	}
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	optr := newFakeOperator([]runtime.Object{deployment}, nil, stopCh)
+	if !cache.WaitForCacheSync(stopCh, optr.deployListerSynced, optr.daemonsetListerSynced) {
+		t.Fatal("failed to sync caches")
+	}
+
+	config := &OperatorConfig{
+		TargetNamespace: targetNamespace,
+		PlatformType:    osconfigv1.AWSPlatformType,
+		Controllers: Controllers{
+			Provider:           "provider-image",
+			TerminationHandler: clusterAPIControllerNoOp,
+		},
+	}
+	if err := optr.statusAvailable(context.Background(), config); err != nil {
+		t.Fatal(err)
+	}
+
+	co, err := optr.getClusterOperator(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var status operatorStatus
+	if err := json.Unmarshal(co.Status.Extension.Raw, &status); err != nil {
+		t.Fatalf("failed to decode status extension: %v", err)
+	}
+	assert.Equal(t, osconfigv1.AWSPlatformType, status.Platform)
+	assert.Equal(t, config.Controllers, status.Images)
+	assert.Equal(t, []operandStatus{{
+		Kind:          "Deployment",
+		Namespace:     targetNamespace,
+		Name:          "machine-api-controllers",
+		Replicas:      1,
+		ReadyReplicas: 1,
+	}}, status.Operands)
+}
+
+func TestOperatorStatusAvailableUnchanged(t *testing.T) {
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	optr := newFakeOperator(nil, nil, stopCh)
+	if !cache.WaitForCacheSync(stopCh, optr.deployListerSynced, optr.daemonsetListerSynced) {
+		t.Fatal("failed to sync caches")
+	}
+
+	config := &OperatorConfig{
+		TargetNamespace: targetNamespace,
+		PlatformType:    osconfigv1.AWSPlatformType,
+		Controllers: Controllers{
+			Provider:           "provider-image",
+			TerminationHandler: clusterAPIControllerNoOp,
+		},
+	}
+	if err := optr.statusAvailable(context.Background(), config); err != nil {
+		t.Fatal(err)
+	}
+
+	osClient := optr.osClient.(*fakeconfigclientset.Clientset)
+	osClient.ClearActions()
+	if err := optr.statusAvailable(context.Background(), config); err != nil {
+		t.Fatal(err)
+	}
+	for _, action := range osClient.Actions() {
+		if action.GetVerb() == "update" {
+			t.Errorf("expected no update of an unchanged status, got %v", action)
+		}
+	}
+}
+
+func TestOperatorStatusDegradedEvents(t *testing.T) {
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	optr := newFakeOperator(nil, nil, stopCh)
+	recorder := optr.eventRecorder.(*record.FakeRecorder)
+
+	countEvents := func() int {
+		n := 0
+		for {
+			select {
+			case <-recorder.Events:
+				n++
+			default:
+				return n
+			}
+		}
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := optr.statusDegraded(context.Background(), fmt.Errorf("sync failed")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assert.Equal(t, 1, countEvents(), "expected a single event for repeated identical errors")
+
+	if err := optr.statusDegraded(context.Background(), fmt.Errorf("another failure")); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 1, countEvents(), "expected an event when the message changes")
+
+	if err := optr.statusAvailable(context.Background(), &OperatorConfig{Controllers: Controllers{Provider: clusterAPIControllerNoOp}}); err != nil {
+		t.Fatal(err)
+	}
+	countEvents()
+	if err := optr.statusDegraded(context.Background(), fmt.Errorf("another failure")); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 1, countEvents(), "expected an event when becoming degraded again")
+}
+
+func TestOperatorStatusStandalone(t *testing.T) {
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	optr := newFakeOperator(nil, nil, stopCh)
+	optr.options.Standalone = true
+
+	if err := optr.statusDegraded(context.Background(), fmt.Errorf("sync failed")); err != nil {
+		t.Fatal(err)
+	}
+	if err := optr.statusAvailable(context.Background(), &OperatorConfig{Controllers: Controllers{Provider: clusterAPIControllerNoOp}}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := optr.osClient.ConfigV1().ClusterOperators().Get(context.Background(), clusterOperatorName, metav1.GetOptions{})
+	assert.True(t, errors.IsNotFound(err), "expected no ClusterOperator to be created, got %v", err)
+
+	cm, err := optr.kubeClient.CoreV1().ConfigMaps(targetNamespace).Get(context.Background(), standaloneStatusConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var status osconfigv1.ClusterOperatorStatus
+	if err := json.Unmarshal([]byte(cm.Data[standaloneStatusKey]), &status); err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, v1helpers.IsStatusConditionTrue(status.Conditions, osconfigv1.OperatorAvailable))
+	assert.True(t, v1helpers.IsStatusConditionFalse(status.Conditions, osconfigv1.OperatorDegraded))
+}
+
+func TestDegradedReason(t *testing.T) {
+	testCases := []struct {
+		err      error
+		expected StatusReason
+	}{
+		{err: fmt.Errorf("%w: infrastructure", ErrConfigNotFound), expected: ReasonConfigNotFound},
+		{err: NewPermanentError(fmt.Errorf("%w: no platform", ErrInvalidProvider)), expected: ReasonInvalidProvider},
+		{err: fmt.Errorf("%w: no images file provided", ErrImagesFileMissing), expected: ReasonImagesFileMissing},
+		{err: fmt.Errorf("rollout timed out"), expected: ReasonSyncFailed},
+	}
+	for _, tc := range testCases {
+		t.Run(string(tc.expected), func(t *testing.T) {
+			assert.Equal(t, tc.expected, degradedReason(tc.err))
+		})
+	}
+}
@@ -1,7 +1,17 @@
 package operator
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/openshift/library-go/pkg/operator/events"
@@ -12,23 +22,27 @@ import (
 	machinecontroller "github.com/openshift/machine-api-operator/pkg/controller/machine"
 	"github.com/openshift/machine-api-operator/pkg/metrics"
 	"github.com/openshift/machine-api-operator/pkg/util/conditions"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/klog/v2"
 	"k8s.io/utils/pointer"
+	"sigs.k8s.io/yaml"
 )
 
 const (
-	deploymentRolloutPollInterval       = time.Second
-	deploymentRolloutTimeout            = 5 * time.Minute
 	deploymentMinimumAvailabilityTime   = 3 * time.Minute
-	daemonsetRolloutPollInterval        = time.Second
-	daemonsetRolloutTimeout             = 5 * time.Minute
 	machineAPITerminationHandler        = "machine-api-termination-handler"
 	machineExposeMetricsPort            = 8441
 	machineSetExposeMetricsPort         = 8442
@@ -41,145 +55,480 @@ const (
 	externalTrustBundleConfigMapName    = "mao-trusted-ca"
 	hostKubeConfigPath                  = "/var/lib/kubelet/kubeconfig"
 	hostKubePKIPath                     = "/var/lib/kubelet/pki"
+	// maxConcurrentSyncs bounds the number of objects applied in parallel.
+	maxConcurrentSyncs = 4
 )
 
-func (optr *Operator) syncAll(config *OperatorConfig) error {
-	if err := optr.statusProgressing(); err != nil {
-		klog.Errorf("Error syncing ClusterOperatorStatus: %v", err)
-		return fmt.Errorf("error syncing ClusterOperatorStatus: %v", err)
+func (optr *Operator) syncAll(ctx context.Context, config *OperatorConfig) error {
+	if optr.options.Observe {
+		// Drift is reported, but none of the operands are written in observe mode.
+		return optr.observeDrift(ctx, config)
+	}
+
+	if err := optr.statusProgressing(ctx); err != nil {
+		klog.ErrorS(err, "Error syncing ClusterOperatorStatus")
+		return fmt.Errorf("error syncing ClusterOperatorStatus: %w", err)
 	}
 
 	if config.Controllers.Provider == clusterAPIControllerNoOp {
-		klog.V(3).Info("Provider is NoOp, skipping synchronisation")
-		if err := optr.statusAvailable(); err != nil {
-			klog.Errorf("Error syncing ClusterOperatorStatus: %v", err)
-			return fmt.Errorf("error syncing ClusterOperatorStatus: %v", err)
+		klog.V(3).InfoS("Provider is NoOp, skipping synchronisation", "targetNamespace", config.TargetNamespace)
+		if err := optr.statusAvailable(ctx, config); err != nil {
+			klog.ErrorS(err, "Error syncing ClusterOperatorStatus")
+			return fmt.Errorf("error syncing ClusterOperatorStatus: %w", err)
 		}
 		return nil
 	}
 
-	// Sync webhook configuration
-	if err := optr.syncWebhookConfiguration(); err != nil {
-		if err := optr.statusDegraded(err.Error()); err != nil {
+	// Every step is run even if an earlier one fails, so one broken
+	// object doesn't hold back the others. All failures are reported together.
+	var errs []error
+	for _, r := range optr.reconcilers {
+		if err := r.Reconcile(ctx, config); err != nil {
+			klog.ErrorS(err, "Error syncing", "step", r.Name(), "targetNamespace", config.TargetNamespace, "provider", config.Controllers.Provider)
+			errs = append(errs, fmt.Errorf("%s: %w", r.Name(), err))
+			continue
+		}
+		klog.V(3).InfoS("Synced up", "step", r.Name(), "targetNamespace", config.TargetNamespace, "provider", config.Controllers.Provider)
+	}
+
+	if err := utilerrors.NewAggregate(errs); err != nil {
+		if err := optr.statusDegraded(ctx, err); err != nil {
 			// Just log the error here.  We still want to
 			// return the outer error.
-			klog.Errorf("Error syncing ClusterOperatorStatus: %v", err)
+			klog.ErrorS(err, "Error syncing ClusterOperatorStatus")
 		}
-		klog.Errorf("Error syncing machine API webhook configurations: %v", err)
 		return err
 	}
-	klog.V(3).Info("Synced up all machine API webhook configurations")
 
-	if err := optr.syncClusterAPIController(config); err != nil {
-		if err := optr.statusDegraded(err.Error()); err != nil {
+	if err := optr.statusAvailable(ctx, config); err != nil {
+		klog.ErrorS(err, "Error syncing ClusterOperatorStatus")
+		return fmt.Errorf("error syncing ClusterOperatorStatus: %w", err)
+	}
+	return nil
+}
+
+// syncWebhooksOnly reconciles just the webhook configurations, for syncs
+// triggered by a change to one of them. The operands and the Available
+// condition are left to the next full sync.
+func (optr *Operator) syncWebhooksOnly(ctx context.Context, config *OperatorConfig) error {
+	if err := optr.syncWebhookConfiguration(ctx, config); err != nil {
+		if err := optr.statusDegraded(ctx, err); err != nil {
 			// Just log the error here.  We still want to
 			// return the outer error.
-			klog.Errorf("Error syncing ClusterOperatorStatus: %v", err)
+			klog.ErrorS(err, "Error syncing ClusterOperatorStatus")
 		}
-		klog.Errorf("Error syncing machine-api-controller: %v", err)
+		klog.ErrorS(err, "Error syncing machine API webhook configurations")
 		return err
 	}
-	klog.V(3).Info("Synced up all machine-api-controller components")
+	klog.V(3).InfoS("Synced up all machine API webhook configurations")
+	return nil
+}
 
-	if err := optr.statusAvailable(); err != nil {
-		klog.Errorf("Error syncing ClusterOperatorStatus: %v", err)
-		return fmt.Errorf("error syncing ClusterOperatorStatus: %v", err)
+// PrintManifests writes every object the operator would apply for the given
+// config to w as a YAML stream. Like WriteManifests it needs no cluster.
+func PrintManifests(config *OperatorConfig, controllersMinAvailable int, w io.Writer) error {
+	if config.Controllers.Provider == clusterAPIControllerNoOp {
+		klog.V(3).Info("Provider is NoOp, nothing to render")
+		return nil
+	}
+	if err := validateManifestConfig(config); err != nil {
+		return err
+	}
+
+	for _, obj := range renderManifests(config, newDeployment(config, nil), controllersMinAvailable) {
+		data, err := yaml.Marshal(obj)
+		if err != nil {
+			return fmt.Errorf("failed to render %T: %w", obj, err)
+		}
+		if _, err := fmt.Fprintf(w, "---\n# %T\n%s", obj, data); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
-func (optr *Operator) syncClusterAPIController(config *OperatorConfig) error {
+// renderManifests returns every object the operator applies for the given config,
+// around the already rendered machine-api-controllers Deployment.
+func renderManifests(config *OperatorConfig, controllersDeployment *appsv1.Deployment, controllersMinAvailable int) []runtime.Object {
+	objects := []runtime.Object{
+		newValidatingWebhookConfiguration(config),
+		newMutatingWebhookConfiguration(config),
+		controllersDeployment,
+		newPodDisruptionBudget(controllersDeployment, controllersMinAvailable),
+	}
+	if config.Controllers.TerminationHandler != clusterAPIControllerNoOp {
+		objects = append(objects, newTerminationDaemonSet(config))
+	}
+	return objects
+}
+
+// manifestKind returns the kind of a rendered object, which unlike objects read
+// from the API server carry no type meta.
+func manifestKind(obj runtime.Object) string {
+	return reflect.TypeOf(obj).Elem().Name()
+}
+
+// WriteManifests renders every object the operator would apply for the given
+// config and writes each of them to dir/<kind>/<name>.yaml. No cluster is
+// needed, so the machine-api-controllers Deployment lacks the annotations
+// hashing its dependencies in the cluster.
+func WriteManifests(config *OperatorConfig, controllersMinAvailable int, dir string) error {
+	if config.Controllers.Provider == clusterAPIControllerNoOp {
+		klog.V(3).Info("Provider is NoOp, nothing to render")
+		return nil
+	}
+	if err := validateManifestConfig(config); err != nil {
+		return err
+	}
+
+	for _, obj := range renderManifests(config, newDeployment(config, nil), controllersMinAvailable) {
+		accessor, err := meta.Accessor(obj)
+		if err != nil {
+			return err
+		}
+		data, err := yaml.Marshal(obj)
+		if err != nil {
+			return fmt.Errorf("failed to render %T: %w", obj, err)
+		}
+
+		kindDir := filepath.Join(dir, manifestKind(obj))
+		if err := os.MkdirAll(kindDir, 0755); err != nil {
+			return err
+		}
+		path := filepath.Join(kindDir, accessor.GetName()+".yaml")
+		if err := ioutil.WriteFile(path, data, 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+		klog.V(2).Infof("Wrote %s", path)
+	}
+	return nil
+}
+
+// validateManifestConfig validates the parts of a config read from a file,
+// which the operator validates when building its config from the cluster.
+func validateManifestConfig(config *OperatorConfig) error {
+	if err := validateExtraVolumes(config); err != nil {
+		return fmt.Errorf("invalid extra volumes: %w", err)
+	}
+	if err := validateResources(config); err != nil {
+		return fmt.Errorf("invalid resources: %w", err)
+	}
+	if err := validateInitContainers(config); err != nil {
+		return fmt.Errorf("invalid init containers: %w", err)
+	}
+	return nil
+}
+
+// newControllersDeployment renders the machine-api-controllers Deployment together
+// with the annotations tracking the external resources it depends on.
+func (optr *Operator) newControllersDeployment(config *OperatorConfig) (*appsv1.Deployment, error) {
 	controllersDeployment := newDeployment(config, nil)
 
 	// we watch some resources so that our deployment will redeploy without explicitly and carefully ordered resource creation
 	inputHashes, err := resourcehash.MultipleObjectHashStringMapForObjectReferences(
 		optr.kubeClient,
-		resourcehash.NewObjectRef().ForConfigMap().InNamespace(config.TargetNamespace).Named(externalTrustBundleConfigMapName),
+		append([]*resourcehash.ObjectReference{
+			resourcehash.NewObjectRef().ForConfigMap().InNamespace(config.TargetNamespace).Named(externalTrustBundleConfigMapName),
+		}, providerCredentialsRefs(config)...)...,
 	)
 	if err != nil {
-		return fmt.Errorf("invalid dependency reference: %q", err)
+		return nil, fmt.Errorf("invalid dependency reference: %w", err)
 	}
 	ensureDependecyAnnotations(inputHashes, controllersDeployment)
+	return controllersDeployment, nil
+}
+
+// providerCredentialsRefs returns the credentials secret of the machine
+// controller of the platform, if it has one, so that the controllers are
+// rolled out again when the credentials rotate.
+func providerCredentialsRefs(config *OperatorConfig) []*resourcehash.ObjectReference {
+	name, ok := providerCredentialsSecrets[config.PlatformType]
+	if !ok {
+		return nil
+	}
+	return []*resourcehash.ObjectReference{
+		resourcehash.NewObjectRef().ForSecret().InNamespace(config.TargetNamespace).Named(name),
+	}
+}
+
+// checkProviderCredentials verifies that the cloud credentials secret the
+// machine controller of the platform needs exists in the target namespace, so
+// that a missing secret is reported as Degraded up front rather than surfacing
+// later as failing machines.
+func (optr *Operator) checkProviderCredentials(ctx context.Context, config *OperatorConfig) error {
+	name, ok := providerCredentialsSecrets[config.PlatformType]
+	if !ok {
+		return nil
+	}
+	_, err := optr.secretLister.Secrets(config.TargetNamespace).Get(name)
+	if apierrors.IsNotFound(err) {
+		return fmt.Errorf("credentials secret %s/%s for platform %s not found", config.TargetNamespace, name, config.PlatformType)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get credentials secret %s/%s: %w", config.TargetNamespace, name, err)
+	}
+	return nil
+}
+
+// syncClusterAPIController syncs the machine-api-controllers Deployment and, when supported
+// by the platform, the termination handler DaemonSet. They don't depend on each other so
+// they are synced, and their rollouts awaited, concurrently.
+func (optr *Operator) syncClusterAPIController(ctx context.Context, config *OperatorConfig) error {
+	syncs := []func(context.Context, *OperatorConfig) error{optr.syncControllersDeployment}
+	if config.Controllers.TerminationHandler != clusterAPIControllerNoOp {
+		syncs = append(syncs, optr.syncTerminationHandler)
+	}
+	return syncConcurrently(ctx, config, syncs...)
+}
+
+// syncConcurrently runs independent syncs in parallel, at most maxConcurrentSyncs
+// at a time, and aggregates all of their errors.
+func syncConcurrently(ctx context.Context, config *OperatorConfig, syncs ...func(context.Context, *OperatorConfig) error) error {
+	errs := make([]error, len(syncs))
+	sem := make(chan struct{}, maxConcurrentSyncs)
+	var wg sync.WaitGroup
+	for i, s := range syncs {
+		wg.Add(1)
+		go func(i int, s func(context.Context, *OperatorConfig) error) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			errs[i] = s(ctx, config)
+		}(i, s)
+	}
+	wg.Wait()
+	return utilerrors.NewAggregate(errs)
+}
+
+func (optr *Operator) syncControllersDeployment(ctx context.Context, config *OperatorConfig) error {
+	controllersDeployment, err := optr.newControllersDeployment(config)
+	if err != nil {
+		return err
+	}
+	if err := checkNamespaceAllowed(config, controllersDeployment); err != nil {
+		return err
+	}
+	setManagedByLabel(controllersDeployment)
+	if err := setConfigHash(controllersDeployment); err != nil {
+		return err
+	}
 
+	optr.generationsLock.Lock()
 	expectedGeneration := resourcemerge.ExpectedDeploymentGeneration(controllersDeployment, optr.generations)
+	optr.generationsLock.Unlock()
+	existing, err := optr.deployLister.Deployments(controllersDeployment.Namespace).Get(controllersDeployment.Name)
+	if err == nil && configHashUnchanged(controllersDeployment, existing, expectedGeneration) {
+		klog.V(4).InfoS("Deployment is up to date, skipping apply", "deployment", klog.KObj(controllersDeployment))
+	} else if err := optr.applyControllersDeployment(ctx, controllersDeployment, expectedGeneration); err != nil {
+		return err
+	}
+
+	if err := optr.syncControllersPodDisruptionBudget(ctx, config, controllersDeployment); err != nil {
+		return err
+	}
+
+	return optr.waitForDeploymentRollout(ctx, controllersDeployment, optr.options.RolloutPollInterval, optr.options.RolloutTimeout)
+}
+
+// applyControllersDeployment applies the rendered controllers Deployment and
+// records its new generation.
+func (optr *Operator) applyControllersDeployment(ctx context.Context, controllersDeployment *appsv1.Deployment, expectedGeneration int64) error {
 	d, updated, err := resourceapply.ApplyDeployment(optr.kubeClient.AppsV1(),
 		events.NewLoggingEventRecorder(optr.name), controllersDeployment, expectedGeneration)
 	if err != nil {
+		optr.eventf(ctx, corev1.EventTypeWarning, "DeploymentUpdateFailed", "Failed to apply Deployment %s/%s: %v",
+			controllersDeployment.Namespace, controllersDeployment.Name, err)
 		return err
 	}
 	if updated {
+		optr.eventf(ctx, corev1.EventTypeNormal, "DeploymentUpdated", "Applied Deployment %s/%s",
+			controllersDeployment.Namespace, controllersDeployment.Name)
+		optr.generationsLock.Lock()
 		resourcemerge.SetDeploymentGeneration(&optr.generations, d)
+		optr.generationsLock.Unlock()
 	}
+	return nil
+}
 
-	if err := optr.waitForDeploymentRollout(controllersDeployment, deploymentRolloutPollInterval, deploymentRolloutTimeout); err != nil {
+// syncControllersPodDisruptionBudget applies the PodDisruptionBudget guarding
+// the given controllers Deployment, so that node drains can't take all of the
+// controllers offline at once.
+func (optr *Operator) syncControllersPodDisruptionBudget(ctx context.Context, config *OperatorConfig, deployment *appsv1.Deployment) error {
+	required := newPodDisruptionBudget(deployment, optr.options.ControllersMinAvailable)
+	if err := checkNamespaceAllowed(config, required); err != nil {
 		return err
 	}
+	setManagedByLabel(required)
 
-	// Sync Termination Handler DaemonSet if supported
-	if config.Controllers.TerminationHandler != clusterAPIControllerNoOp {
-		if err := optr.syncTerminationHandler(config); err != nil {
-			return err
+	client := optr.kubeClient.PolicyV1beta1().PodDisruptionBudgets(required.Namespace)
+	existing, err := client.Get(ctx, required.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		if _, err := client.Create(ctx, required, metav1.CreateOptions{}); err != nil {
+			optr.eventf(ctx, corev1.EventTypeWarning, "PodDisruptionBudgetCreateFailed", "Failed to create PodDisruptionBudget %s/%s: %v",
+				required.Namespace, required.Name, err)
+			return fmt.Errorf("failed to create PodDisruptionBudget %s/%s: %w", required.Namespace, required.Name, err)
 		}
+		optr.eventf(ctx, corev1.EventTypeNormal, "PodDisruptionBudgetCreated", "Created PodDisruptionBudget %s/%s",
+			required.Namespace, required.Name)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get PodDisruptionBudget %s/%s: %w", required.Namespace, required.Name, err)
 	}
 
+	modified := resourcemerge.BoolPtr(false)
+	existingCopy := existing.DeepCopy()
+	resourcemerge.EnsureObjectMeta(modified, &existingCopy.ObjectMeta, required.ObjectMeta)
+	if !*modified && equality.Semantic.DeepEqual(existingCopy.Spec, required.Spec) {
+		return nil
+	}
+	existingCopy.Spec = required.Spec
+	if _, err := client.Update(ctx, existingCopy, metav1.UpdateOptions{}); err != nil {
+		optr.eventf(ctx, corev1.EventTypeWarning, "PodDisruptionBudgetUpdateFailed", "Failed to apply PodDisruptionBudget %s/%s: %v",
+			required.Namespace, required.Name, err)
+		return fmt.Errorf("failed to update PodDisruptionBudget %s/%s: %w", required.Namespace, required.Name, err)
+	}
+	optr.eventf(ctx, corev1.EventTypeNormal, "PodDisruptionBudgetUpdated", "Applied PodDisruptionBudget %s/%s",
+		required.Namespace, required.Name)
 	return nil
 }
 
-func (optr *Operator) syncTerminationHandler(config *OperatorConfig) error {
+func (optr *Operator) syncTerminationHandler(ctx context.Context, config *OperatorConfig) error {
 	terminationDaemonSet := newTerminationDaemonSet(config)
+	if err := checkNamespaceAllowed(config, terminationDaemonSet); err != nil {
+		return err
+	}
+	setManagedByLabel(terminationDaemonSet)
+	if err := setConfigHash(terminationDaemonSet); err != nil {
+		return err
+	}
+	optr.generationsLock.Lock()
 	expectedGeneration := resourcemerge.ExpectedDaemonSetGeneration(terminationDaemonSet, optr.generations)
+	optr.generationsLock.Unlock()
+	existing, err := optr.daemonsetLister.DaemonSets(terminationDaemonSet.Namespace).Get(terminationDaemonSet.Name)
+	if err == nil && configHashUnchanged(terminationDaemonSet, existing, expectedGeneration) {
+		klog.V(4).InfoS("DaemonSet is up to date, skipping apply", "daemonset", klog.KObj(terminationDaemonSet))
+		return optr.waitForDaemonSetRollout(ctx, terminationDaemonSet, optr.options.RolloutPollInterval, optr.options.RolloutTimeout)
+	}
 	ds, updated, err := resourceapply.ApplyDaemonSet(optr.kubeClient.AppsV1(),
 		events.NewLoggingEventRecorder(optr.name), terminationDaemonSet, expectedGeneration)
 	if err != nil {
+		optr.eventf(ctx, corev1.EventTypeWarning, "DaemonSetUpdateFailed", "Failed to apply DaemonSet %s/%s: %v",
+			terminationDaemonSet.Namespace, terminationDaemonSet.Name, err)
 		return err
 	}
 	if updated {
+		optr.eventf(ctx, corev1.EventTypeNormal, "DaemonSetUpdated", "Applied DaemonSet %s/%s",
+			terminationDaemonSet.Namespace, terminationDaemonSet.Name)
+		optr.generationsLock.Lock()
 		resourcemerge.SetDaemonSetGeneration(&optr.generations, ds)
+		optr.generationsLock.Unlock()
 	}
-	return optr.waitForDaemonSetRollout(terminationDaemonSet)
+	return optr.waitForDaemonSetRollout(ctx, terminationDaemonSet, optr.options.RolloutPollInterval, optr.options.RolloutTimeout)
 }
 
-func (optr *Operator) syncWebhookConfiguration() error {
-	if err := optr.syncValidatingWebhook(); err != nil {
-		return err
-	}
-
-	return optr.syncMutatingWebhook()
+// syncWebhookConfiguration applies both webhook configurations concurrently, even
+// if one of them fails, and returns the aggregated errors.
+func (optr *Operator) syncWebhookConfiguration(ctx context.Context, config *OperatorConfig) error {
+	return syncConcurrently(ctx, config, optr.syncValidatingWebhook, optr.syncMutatingWebhook)
 }
 
-func (optr *Operator) syncValidatingWebhook() error {
-	expectedGeneration := resourcemerge.ExpectedValidatingWebhooksConfiguration(mapiv1.NewValidatingWebhookConfiguration().Name, optr.generations)
+func (optr *Operator) syncValidatingWebhook(ctx context.Context, config *OperatorConfig) error {
+	webhookConfiguration := newValidatingWebhookConfiguration(config)
+	setManagedByLabel(webhookConfiguration)
+	if err := setConfigHash(webhookConfiguration); err != nil {
+		return err
+	}
+	optr.generationsLock.Lock()
+	expectedGeneration := resourcemerge.ExpectedValidatingWebhooksConfiguration(webhookConfiguration.Name, optr.generations)
+	optr.generationsLock.Unlock()
+	existing, err := optr.validatingWebhookLister.Get(webhookConfiguration.Name)
+	if err == nil && configHashUnchanged(webhookConfiguration, existing, expectedGeneration) {
+		klog.V(4).InfoS("ValidatingWebhookConfiguration is up to date, skipping apply", "name", webhookConfiguration.Name)
+		return nil
+	}
+	// resourceapply takes no context, don't start applying once the sync is cancelled.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	validatingWebhook, updated, err := resourceapply.ApplyValidatingWebhookConfiguration(optr.kubeClient.AdmissionregistrationV1(),
 		events.NewLoggingEventRecorder(optr.name),
-		mapiv1.NewValidatingWebhookConfiguration(), expectedGeneration)
+		webhookConfiguration, expectedGeneration)
 	if err != nil {
 		return err
 	}
 	if updated {
+		optr.generationsLock.Lock()
 		resourcemerge.SetValidatingWebhooksConfigurationGeneration(&optr.generations, validatingWebhook)
+		optr.generationsLock.Unlock()
 	}
 
 	return nil
 }
 
-func (optr *Operator) syncMutatingWebhook() error {
-	expectedGeneration := resourcemerge.ExpectedMutatingWebhooksConfiguration(mapiv1.NewMutatingWebhookConfiguration().Name, optr.generations)
+func (optr *Operator) syncMutatingWebhook(ctx context.Context, config *OperatorConfig) error {
+	webhookConfiguration := newMutatingWebhookConfiguration(config)
+	setManagedByLabel(webhookConfiguration)
+	if err := setConfigHash(webhookConfiguration); err != nil {
+		return err
+	}
+	optr.generationsLock.Lock()
+	expectedGeneration := resourcemerge.ExpectedMutatingWebhooksConfiguration(webhookConfiguration.Name, optr.generations)
+	optr.generationsLock.Unlock()
+	existing, err := optr.mutatingWebhookLister.Get(webhookConfiguration.Name)
+	if err == nil && configHashUnchanged(webhookConfiguration, existing, expectedGeneration) {
+		klog.V(4).InfoS("MutatingWebhookConfiguration is up to date, skipping apply", "name", webhookConfiguration.Name)
+		return nil
+	}
+	// resourceapply takes no context, don't start applying once the sync is cancelled.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	validatingWebhook, updated, err := resourceapply.ApplyMutatingWebhookConfiguration(optr.kubeClient.AdmissionregistrationV1(),
 		events.NewLoggingEventRecorder(optr.name),
-		mapiv1.NewMutatingWebhookConfiguration(), expectedGeneration)
+		webhookConfiguration, expectedGeneration)
 	if err != nil {
 		return err
 	}
 	if updated {
+		optr.generationsLock.Lock()
 		resourcemerge.SetMutatingWebhooksConfigurationGeneration(&optr.generations, validatingWebhook)
+		optr.generationsLock.Unlock()
 	}
 
 	return nil
 }
 
-func (optr *Operator) waitForDeploymentRollout(resource *appsv1.Deployment, pollInterval, rolloutTimeout time.Duration) error {
+// newValidatingWebhookConfiguration returns the machine API validating webhook configuration
+// calling the webhook service in the target namespace.
+func newValidatingWebhookConfiguration(config *OperatorConfig) *admissionregistrationv1.ValidatingWebhookConfiguration {
+	webhookConfiguration := mapiv1.NewValidatingWebhookConfiguration()
+	for i := range webhookConfiguration.Webhooks {
+		if service := webhookConfiguration.Webhooks[i].ClientConfig.Service; service != nil {
+			service.Namespace = config.TargetNamespace
+		}
+	}
+	return webhookConfiguration
+}
+
+// newMutatingWebhookConfiguration returns the machine API mutating webhook configuration
+// calling the webhook service in the target namespace.
+func newMutatingWebhookConfiguration(config *OperatorConfig) *admissionregistrationv1.MutatingWebhookConfiguration {
+	webhookConfiguration := mapiv1.NewMutatingWebhookConfiguration()
+	for i := range webhookConfiguration.Webhooks {
+		if service := webhookConfiguration.Webhooks[i].ClientConfig.Service; service != nil {
+			service.Namespace = config.TargetNamespace
+		}
+	}
+	return webhookConfiguration
+}
+
+func (optr *Operator) waitForDeploymentRollout(ctx context.Context, resource *appsv1.Deployment, pollInterval, rolloutTimeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, rolloutTimeout)
+	defer cancel()
+
 	var lastError error
-	err := wait.Poll(pollInterval, rolloutTimeout, func() (bool, error) {
+	err := wait.PollUntil(pollInterval, func() (bool, error) {
 		d, err := optr.deployLister.Deployments(resource.Namespace).Get(resource.Name)
 		if apierrors.IsNotFound(err) {
 			lastError = fmt.Errorf("deployment %s is not found", resource.Name)
@@ -189,7 +538,7 @@ func (optr *Operator) waitForDeploymentRollout(resource *appsv1.Deployment, poll
 		if err != nil {
 			// Do not return error here, as we could be updating the API Server itself, in which case we
 			// want to continue waiting.
-			lastError = fmt.Errorf("getting Deployment %s during rollout: %v", resource.Name, err)
+			lastError = fmt.Errorf("getting Deployment %s during rollout: %w", resource.Name, err)
 			klog.Error(lastError)
 			return false, nil
 		}
@@ -224,16 +573,19 @@ func (optr *Operator) waitForDeploymentRollout(resource *appsv1.Deployment, poll
 		lastError = fmt.Errorf("deployment %s is not ready. status: (replicas: %d, updated: %d, ready: %d, unavailable: %d)", d.Name, d.Status.Replicas, d.Status.UpdatedReplicas, d.Status.ReadyReplicas, d.Status.UnavailableReplicas)
 		klog.V(4).Info(lastError)
 		return false, nil
-	})
+	}, ctx.Done())
 	if lastError != nil {
 		return lastError
 	}
 	return err
 }
 
-func (optr *Operator) waitForDaemonSetRollout(resource *appsv1.DaemonSet) error {
+func (optr *Operator) waitForDaemonSetRollout(ctx context.Context, resource *appsv1.DaemonSet, pollInterval, rolloutTimeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, rolloutTimeout)
+	defer cancel()
+
 	var lastError error
-	err := wait.Poll(daemonsetRolloutPollInterval, daemonsetRolloutTimeout, func() (bool, error) {
+	err := wait.PollUntil(pollInterval, func() (bool, error) {
 		d, err := optr.daemonsetLister.DaemonSets(resource.Namespace).Get(resource.Name)
 		if apierrors.IsNotFound(err) {
 			return false, nil
@@ -241,7 +593,7 @@ func (optr *Operator) waitForDaemonSetRollout(resource *appsv1.DaemonSet) error
 		if err != nil {
 			// Do not return error here, as we could be updating the API Server itself, in which case we
 			// want to continue waiting.
-			lastError = fmt.Errorf("getting DaemonSet %s during rollout: %v", resource.Name, err)
+			lastError = fmt.Errorf("getting DaemonSet %s during rollout: %w", resource.Name, err)
 			klog.Error(lastError)
 			return false, nil
 		}
@@ -258,7 +610,7 @@ func (optr *Operator) waitForDaemonSetRollout(resource *appsv1.DaemonSet) error
 		lastError = fmt.Errorf("daemonset %s is not ready. status: (desired: %d, updated: %d, available: %d, unavailable: %d)", d.Name, d.Status.DesiredNumberScheduled, d.Status.UpdatedNumberScheduled, d.Status.NumberAvailable, d.Status.NumberUnavailable)
 		klog.V(4).Info(lastError)
 		return false, nil
-	})
+	}, ctx.Done())
 	if lastError != nil {
 		return lastError
 	}
@@ -267,6 +619,9 @@ func (optr *Operator) waitForDaemonSetRollout(resource *appsv1.DaemonSet) error
 
 func newDeployment(config *OperatorConfig, features map[string]bool) *appsv1.Deployment {
 	replicas := int32(1)
+	if config.ControllerReplicas > 0 {
+		replicas = config.ControllerReplicas
+	}
 	template := newPodTemplateSpec(config, features)
 
 	return &appsv1.Deployment{
@@ -294,6 +649,136 @@ func newDeployment(config *OperatorConfig, features map[string]bool) *appsv1.Dep
 	}
 }
 
+// newPodDisruptionBudget renders the PodDisruptionBudget of the given
+// Deployment. A negative minAvailable is derived from the replicas of the
+// Deployment so that one of its pods at a time can be disrupted.
+func newPodDisruptionBudget(deployment *appsv1.Deployment, minAvailable int) *policyv1beta1.PodDisruptionBudget {
+	if minAvailable < 0 {
+		replicas := 1
+		if deployment.Spec.Replicas != nil {
+			replicas = int(*deployment.Spec.Replicas)
+		}
+		minAvailable = replicas - 1
+		if minAvailable < 0 {
+			minAvailable = 0
+		}
+	}
+	min := intstr.FromInt(minAvailable)
+
+	return &policyv1beta1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      deployment.Name,
+			Namespace: deployment.Namespace,
+			Labels:    deployment.Labels,
+		},
+		Spec: policyv1beta1.PodDisruptionBudgetSpec{
+			MinAvailable: &min,
+			Selector:     deployment.Spec.Selector.DeepCopy(),
+		},
+	}
+}
+
+// validateExtraVolumes checks that the extra volumes of the config don't clash
+// with the volumes of the machine-api-controllers pods or with each other, and
+// that the extra mounts only use volumes of the pods and free mount paths of
+// the machine-controller container.
+func validateExtraVolumes(config *OperatorConfig) error {
+	spec := newPodTemplateSpec(&OperatorConfig{TargetNamespace: config.TargetNamespace}, nil).Spec
+
+	volumes := sets.NewString()
+	for _, v := range spec.Volumes {
+		volumes.Insert(v.Name)
+	}
+	var errs []error
+	for _, v := range config.ExtraVolumes {
+		if volumes.Has(v.Name) {
+			errs = append(errs, fmt.Errorf("extra volume %q clashes with an existing volume", v.Name))
+			continue
+		}
+		volumes.Insert(v.Name)
+	}
+
+	mountPaths := sets.NewString()
+	for _, c := range spec.Containers {
+		if c.Name != "machine-controller" {
+			continue
+		}
+		for _, m := range c.VolumeMounts {
+			mountPaths.Insert(m.MountPath)
+		}
+	}
+	for _, m := range config.ExtraVolumeMounts {
+		if !volumes.Has(m.Name) {
+			errs = append(errs, fmt.Errorf("extra volume mount %q refers to an unknown volume", m.Name))
+		}
+		if mountPaths.Has(m.MountPath) {
+			errs = append(errs, fmt.Errorf("extra volume mount %q clashes with an existing mount at %s", m.Name, m.MountPath))
+			continue
+		}
+		mountPaths.Insert(m.MountPath)
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// resourcesComponents are the keys accepted in the resources of the config.
+var resourcesComponents = sets.NewString(
+	"machineset-controller",
+	"machine-controller",
+	"nodelink-controller",
+	"machine-healthcheck-controller",
+	"kube-rbac-proxy",
+	"termination-handler",
+)
+
+// validateResources checks that the resources of the config are only set
+// for known components.
+func validateResources(config *OperatorConfig) error {
+	var errs []error
+	for name := range config.Resources {
+		if !resourcesComponents.Has(name) {
+			errs = append(errs, fmt.Errorf("unknown component %q, must be one of %s", name, strings.Join(resourcesComponents.List(), ", ")))
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// validateInitContainers checks that the init containers of the config are
+// named and that their names clash neither with the containers of the
+// machine-api-controllers pods nor with each other.
+func validateInitContainers(config *OperatorConfig) error {
+	names := sets.NewString()
+	for _, c := range newPodTemplateSpec(&OperatorConfig{TargetNamespace: config.TargetNamespace}, nil).Spec.Containers {
+		names.Insert(c.Name)
+	}
+	var errs []error
+	for _, c := range config.InitContainers {
+		if c.Name == "" {
+			errs = append(errs, fmt.Errorf("init container with image %q has no name", c.Image))
+			continue
+		}
+		if names.Has(c.Name) {
+			errs = append(errs, fmt.Errorf("init container name %q clashes with another container", c.Name))
+			continue
+		}
+		names.Insert(c.Name)
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// setContainerResources replaces the default resource requirements of the
+// given containers by the ones set for them in the resources of the config.
+func setContainerResources(config *OperatorConfig, containers []corev1.Container) {
+	for i := range containers {
+		name := containers[i].Name
+		if strings.HasPrefix(name, "kube-rbac-proxy-") {
+			name = "kube-rbac-proxy"
+		}
+		if resources, ok := config.Resources[name]; ok {
+			containers[i].Resources = resources
+		}
+	}
+}
+
 // List of the volumes needed by newKubeProxyContainer
 func newRBACConfigVolumes() []corev1.Volume {
 	var readOnly int32 = 420
@@ -334,8 +819,19 @@ func newRBACConfigVolumes() []corev1.Volume {
 }
 
 func newPodTemplateSpec(config *OperatorConfig, features map[string]bool) *corev1.PodTemplateSpec {
-	containers := newContainers(config, features)
-	proxyContainers := newKubeProxyContainers(config.Controllers.KubeRBACProxy)
+	containers := append(newContainers(config, features), newKubeProxyContainers(config.Controllers.KubeRBACProxy)...)
+	for i := range containers {
+		containers[i].ImagePullPolicy = config.ImagePullPolicy
+	}
+	var initContainers []corev1.Container
+	for _, c := range config.InitContainers {
+		c := *c.DeepCopy()
+		if c.ImagePullPolicy == "" {
+			c.ImagePullPolicy = config.ImagePullPolicy
+		}
+		initContainers = append(initContainers, c)
+	}
+	setContainerResources(config, containers)
 	tolerations := []corev1.Toleration{
 		{
 			Key:    "node-role.kubernetes.io/master",
@@ -358,6 +854,7 @@ func newPodTemplateSpec(config *OperatorConfig, features map[string]bool) *corev
 			TolerationSeconds: pointer.Int64Ptr(120),
 		},
 	}
+	tolerations = append(tolerations, config.Tolerations...)
 
 	var readOnly int32 = 420
 	volumes := []corev1.Volume{
@@ -397,6 +894,7 @@ func newPodTemplateSpec(config *OperatorConfig, features map[string]bool) *corev
 		},
 	}
 	volumes = append(volumes, newRBACConfigVolumes()...)
+	volumes = append(volumes, config.ExtraVolumes...)
 
 	return &corev1.PodTemplateSpec{
 		ObjectMeta: metav1.ObjectMeta{
@@ -406,16 +904,20 @@ func newPodTemplateSpec(config *OperatorConfig, features map[string]bool) *corev
 			},
 		},
 		Spec: corev1.PodSpec{
-			Containers:         append(containers, proxyContainers...),
-			PriorityClassName:  "system-node-critical",
-			NodeSelector:       map[string]string{"node-role.kubernetes.io/master": ""},
-			ServiceAccountName: "machine-api-controllers",
-			Tolerations:        tolerations,
-			Volumes:            volumes,
+			InitContainers:            initContainers,
+			Containers:                containers,
+			PriorityClassName:         "system-node-critical",
+			NodeSelector:              map[string]string{"node-role.kubernetes.io/master": ""},
+			ServiceAccountName:        "machine-api-controllers",
+			Tolerations:               tolerations,
+			Volumes:                   volumes,
+			TopologySpreadConstraints: config.TopologySpreadConstraints,
 		},
 	}
 }
 
+// getProxyArgs returns the proxy environment of the controllers from the
+// status of the cluster-wide proxy, which holds the settings in effect.
 func getProxyArgs(config *OperatorConfig) []corev1.EnvVar {
 	var envVars []corev1.EnvVar
 
@@ -425,13 +927,13 @@ func getProxyArgs(config *OperatorConfig) []corev1.EnvVar {
 	if config.Proxy.Status.HTTPProxy != "" {
 		envVars = append(envVars, corev1.EnvVar{
 			Name:  "HTTP_PROXY",
-			Value: config.Proxy.Spec.HTTPProxy,
+			Value: config.Proxy.Status.HTTPProxy,
 		})
 	}
 	if config.Proxy.Status.HTTPSProxy != "" {
 		envVars = append(envVars, corev1.EnvVar{
 			Name:  "HTTPS_PROXY",
-			Value: config.Proxy.Spec.HTTPSProxy,
+			Value: config.Proxy.Status.HTTPSProxy,
 		})
 	}
 	if config.Proxy.Status.NoProxy != "" {
@@ -453,6 +955,8 @@ func newContainers(config *OperatorConfig, features map[string]bool) []corev1.Co
 	args := []string{
 		"--logtostderr=true",
 		"--v=3",
+		// Leader election is always enabled, so the Deployment can be scaled
+		// up without the replicas reconciling the same objects.
 		"--leader-elect=true",
 		"--leader-elect-lease-duration=120s",
 		fmt.Sprintf("--namespace=%s", config.TargetNamespace),
@@ -536,7 +1040,7 @@ func newContainers(config *OperatorConfig, features map[string]bool) []corev1.Co
 					},
 				},
 			},
-			VolumeMounts: []corev1.VolumeMount{
+			VolumeMounts: append([]corev1.VolumeMount{
 				{
 					MountPath: "/etc/pki/ca-trust/extracted/pem",
 					Name:      "trusted-ca",
@@ -547,7 +1051,7 @@ func newContainers(config *OperatorConfig, features map[string]bool) []corev1.Co
 					Name:      "bound-sa-token",
 					ReadOnly:  true,
 				},
-			},
+			}, config.ExtraVolumeMounts...),
 		},
 		{
 			Name:      "nodelink-controller",
@@ -669,6 +1173,7 @@ func newTerminationDaemonSet(config *OperatorConfig) *appsv1.DaemonSet {
 
 func newTerminationPodTemplateSpec(config *OperatorConfig) *corev1.PodTemplateSpec {
 	containers := newTerminationContainers(config)
+	setContainerResources(config, containers)
 
 	return &corev1.PodTemplateSpec{
 		ObjectMeta: metav1.ObjectMeta{
@@ -771,3 +1276,53 @@ func ensureDependecyAnnotations(inputHashes map[string]string, deployment *appsv
 		deployment.Spec.Template.Annotations[annotationKey] = v
 	}
 }
+
+// setConfigHash annotates obj with the hash of its rendered form, which changes
+// whenever anything the operator renders into obj does.
+func setConfigHash(obj metav1.Object) error {
+	annotations := obj.GetAnnotations()
+	delete(annotations, configHashAnnotation)
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return fmt.Errorf("failed to hash %s: %w", obj.GetName(), err)
+	}
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[configHashAnnotation] = fmt.Sprintf("%x", sha256.Sum256(data))
+	obj.SetAnnotations(annotations)
+	return nil
+}
+
+// configHashUnchanged returns whether existing was applied from the same rendered
+// object as required and its generation is still the one the operator last
+// applied, i.e. nobody else changed it since. Applying required would be a no-op then.
+func configHashUnchanged(required, existing metav1.Object, expectedGeneration int64) bool {
+	hash, ok := existing.GetAnnotations()[configHashAnnotation]
+	return ok && hash == required.GetAnnotations()[configHashAnnotation] && existing.GetGeneration() == expectedGeneration
+}
+
+// checkNamespaceAllowed returns an error wrapping ErrNamespaceNotAllowed if obj
+// is namespaced and its namespace is not one of the allowed namespaces of the
+// config. It is checked before any operand is written.
+func checkNamespaceAllowed(config *OperatorConfig, obj metav1.Object) error {
+	allowed := sets.NewString(config.AllowedNamespaces...)
+	if allowed.Len() == 0 {
+		allowed.Insert(config.TargetNamespace)
+	}
+	if obj.GetNamespace() == "" || allowed.Has(obj.GetNamespace()) {
+		return nil
+	}
+	return NewPermanentError(fmt.Errorf("%w: refusing to write %s into namespace %s, allowed namespaces are %s",
+		ErrNamespaceNotAllowed, obj.GetName(), obj.GetNamespace(), strings.Join(allowed.List(), ", ")))
+}
+
+// setManagedByLabel labels obj as managed by the operator.
+func setManagedByLabel(obj metav1.Object) {
+	labels := obj.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[managedByLabel] = managedByLabelValue
+	obj.SetLabels(labels)
+}
@@ -1,16 +1,34 @@
 package operator
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	configv1 "github.com/openshift/api/config/v1"
+	"github.com/openshift/library-go/pkg/config/clusteroperator/v1helpers"
+	mapiv1 "github.com/openshift/machine-api-operator/pkg/apis/machine/v1beta1"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/diff"
+	fakekube "k8s.io/client-go/kubernetes/fake"
+	admissionlisterv1 "k8s.io/client-go/listers/admissionregistration/v1"
+	clienttesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/pointer"
 )
 
 func TestWaitForDeploymentRollout(t *testing.T) {
@@ -109,7 +127,7 @@ func TestWaitForDeploymentRollout(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			optr := newFakeOperator([]runtime.Object{tc.deployment}, nil, make(<-chan struct{}))
 
-			got := optr.waitForDeploymentRollout(tc.deployment, 1*time.Second, 3*time.Second)
+			got := optr.waitForDeploymentRollout(context.Background(), tc.deployment, 10*time.Millisecond, 100*time.Millisecond)
 			if tc.expected != nil {
 				if tc.expected.Error() != got.Error() {
 					t.Errorf("Got: %v, expected: %v", got, tc.expected)
@@ -121,6 +139,47 @@ func TestWaitForDeploymentRollout(t *testing.T) {
 	}
 }
 
+func TestWaitForDeploymentRolloutCancelled(t *testing.T) {
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: targetNamespace,
+		},
+	}
+	optr := newFakeOperator(nil, nil, make(<-chan struct{}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	start := time.Now()
+	err := optr.waitForDeploymentRollout(ctx, deployment, 100*time.Millisecond, time.Minute)
+	if err == nil {
+		t.Fatal("expected an error when the context is cancelled")
+	}
+	if time.Since(start) > 10*time.Second {
+		t.Errorf("rollout wait did not return promptly after the context was cancelled")
+	}
+}
+
+func TestWaitForDaemonSetRolloutTimeout(t *testing.T) {
+	daemonSet := &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: targetNamespace,
+		},
+	}
+	optr := newFakeOperator(nil, nil, make(<-chan struct{}))
+
+	start := time.Now()
+	err := optr.waitForDaemonSetRollout(context.Background(), daemonSet, 50*time.Millisecond, 300*time.Millisecond)
+	if err == nil {
+		t.Fatal("expected an error when the daemonset does not roll out in time")
+	}
+	if time.Since(start) > 10*time.Second {
+		t.Errorf("rollout wait did not return promptly after the rollout timeout")
+	}
+}
+
 func Test_ensureDependecyAnnotations(t *testing.T) {
 	cases := []struct {
 		name string
@@ -241,3 +300,964 @@ func Test_ensureDependecyAnnotations(t *testing.T) {
 		})
 	}
 }
+
+func TestPrintManifests(t *testing.T) {
+	testCases := []struct {
+		name             string
+		controllers      Controllers
+		expectedKinds    []string
+		notExpectedKinds []string
+	}{
+		{
+			name: "NoOp provider renders nothing",
+			controllers: Controllers{
+				Provider:           clusterAPIControllerNoOp,
+				TerminationHandler: clusterAPIControllerNoOp,
+			},
+			notExpectedKinds: []string{"*v1.Deployment", "*v1.DaemonSet", "*v1beta1.PodDisruptionBudget"},
+		},
+		{
+			name: "Provider without termination handler",
+			controllers: Controllers{
+				Provider:           "provider-image",
+				TerminationHandler: clusterAPIControllerNoOp,
+			},
+			expectedKinds:    []string{"*v1.ValidatingWebhookConfiguration", "*v1.MutatingWebhookConfiguration", "*v1.Deployment", "*v1beta1.PodDisruptionBudget"},
+			notExpectedKinds: []string{"*v1.DaemonSet"},
+		},
+		{
+			name: "Provider with termination handler",
+			controllers: Controllers{
+				Provider:           "provider-image",
+				TerminationHandler: "provider-image",
+			},
+			expectedKinds: []string{"*v1.ValidatingWebhookConfiguration", "*v1.MutatingWebhookConfiguration", "*v1.Deployment", "*v1.DaemonSet"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := &OperatorConfig{
+				TargetNamespace: targetNamespace,
+				Controllers:     tc.controllers,
+			}
+
+			buf := &bytes.Buffer{}
+			if err := PrintManifests(config, DefaultControllersMinAvailable, buf); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for _, kind := range tc.expectedKinds {
+				if !strings.Contains(buf.String(), fmt.Sprintf("# %s\n", kind)) {
+					t.Errorf("expected %s to be rendered, got: %s", kind, buf.String())
+				}
+			}
+			for _, kind := range tc.notExpectedKinds {
+				if strings.Contains(buf.String(), fmt.Sprintf("# %s\n", kind)) {
+					t.Errorf("expected %s not to be rendered, got: %s", kind, buf.String())
+				}
+			}
+		})
+	}
+}
+
+func TestCheckProviderCredentials(t *testing.T) {
+	testCases := []struct {
+		name          string
+		platform      configv1.PlatformType
+		objects       []runtime.Object
+		expectedError string
+	}{
+		{
+			name:     "secret present",
+			platform: configv1.AWSPlatformType,
+			objects: []runtime.Object{&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "aws-cloud-credentials", Namespace: targetNamespace},
+			}},
+		},
+		{
+			name:          "secret missing",
+			platform:      configv1.AWSPlatformType,
+			expectedError: "credentials secret test-namespace/aws-cloud-credentials for platform AWS not found",
+		},
+		{
+			name:     "platform without credentials",
+			platform: configv1.BareMetalPlatformType,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			stopCh := make(chan struct{})
+			defer close(stopCh)
+			optr := newFakeOperator(tc.objects, nil, stopCh)
+			if !cache.WaitForCacheSync(stopCh, optr.secretListerSynced) {
+				t.Fatal("failed to sync caches")
+			}
+
+			err := optr.checkProviderCredentials(context.Background(), &OperatorConfig{
+				TargetNamespace: targetNamespace,
+				PlatformType:    tc.platform,
+			})
+			if tc.expectedError == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tc.expectedError {
+				t.Errorf("expected error %q, got: %v", tc.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestGetProxyArgs(t *testing.T) {
+	config := &OperatorConfig{
+		Proxy: &configv1.Proxy{
+			Spec: configv1.ProxySpec{
+				HTTPProxy:  "http://spec.example.com",
+				HTTPSProxy: "https://spec.example.com",
+			},
+			Status: configv1.ProxyStatus{
+				HTTPProxy:  "http://proxy.example.com",
+				HTTPSProxy: "https://proxy.example.com",
+				NoProxy:    ".cluster.local",
+			},
+		},
+	}
+	expected := []corev1.EnvVar{
+		{Name: "HTTP_PROXY", Value: "http://proxy.example.com"},
+		{Name: "HTTPS_PROXY", Value: "https://proxy.example.com"},
+		{Name: "NO_PROXY", Value: ".cluster.local"},
+	}
+	if got := getProxyArgs(config); !equality.Semantic.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+
+	if got := getProxyArgs(&OperatorConfig{Proxy: &configv1.Proxy{}}); len(got) != 0 {
+		t.Errorf("expected no proxy environment without a proxy, got %v", got)
+	}
+}
+
+func TestNewDeploymentReplicas(t *testing.T) {
+	testCases := []struct {
+		name     string
+		replicas int32
+		expected int32
+	}{
+		{
+			name:     "unset renders a single replica",
+			expected: 1,
+		},
+		{
+			name:     "configured replicas",
+			replicas: 3,
+			expected: 3,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			deployment := newDeployment(&OperatorConfig{TargetNamespace: targetNamespace, ControllerReplicas: tc.replicas}, nil)
+			if *deployment.Spec.Replicas != tc.expected {
+				t.Errorf("expected %d replicas, got %d", tc.expected, *deployment.Spec.Replicas)
+			}
+			for _, c := range deployment.Spec.Template.Spec.Containers {
+				if strings.HasPrefix(c.Name, "kube-rbac-proxy") {
+					continue
+				}
+				found := false
+				for _, arg := range c.Args {
+					if arg == "--leader-elect=true" {
+						found = true
+					}
+				}
+				if !found {
+					t.Errorf("expected leader election to be enabled on container %s, got args %v", c.Name, c.Args)
+				}
+			}
+		})
+	}
+}
+
+func TestNewDeploymentTolerations(t *testing.T) {
+	extra := corev1.Toleration{
+		Key:      "node-role.kubernetes.io/infra",
+		Operator: corev1.TolerationOpExists,
+		Effect:   corev1.TaintEffectNoSchedule,
+	}
+
+	defaults := newDeployment(&OperatorConfig{TargetNamespace: targetNamespace}, nil).Spec.Template.Spec.Tolerations
+	withExtra := newDeployment(&OperatorConfig{
+		TargetNamespace: targetNamespace,
+		Tolerations:     []corev1.Toleration{extra},
+	}, nil).Spec.Template.Spec.Tolerations
+
+	if !equality.Semantic.DeepEqual(withExtra, append(defaults, extra)) {
+		t.Errorf("expected the extra toleration to be appended to the defaults, got: %v", withExtra)
+	}
+}
+
+func TestNewDeploymentExtraVolumes(t *testing.T) {
+	config := &OperatorConfig{
+		TargetNamespace: targetNamespace,
+		ExtraVolumes: []corev1.Volume{{
+			Name:         "plugin-credentials",
+			VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: "plugin-credentials"}},
+		}},
+		ExtraVolumeMounts: []corev1.VolumeMount{{
+			Name:      "plugin-credentials",
+			MountPath: "/etc/plugin",
+		}},
+	}
+	if err := validateExtraVolumes(config); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	spec := newDeployment(config, nil).Spec.Template.Spec
+	if last := spec.Volumes[len(spec.Volumes)-1]; last.Name != "plugin-credentials" {
+		t.Errorf("expected the extra volume to be added, got: %v", spec.Volumes)
+	}
+	for _, c := range spec.Containers {
+		var mounted bool
+		for _, m := range c.VolumeMounts {
+			mounted = mounted || m.Name == "plugin-credentials"
+		}
+		if mounted != (c.Name == "machine-controller") {
+			t.Errorf("expected the extra volume to be mounted only into machine-controller, got mounted=%v for %s", mounted, c.Name)
+		}
+	}
+}
+
+func TestValidateExtraVolumes(t *testing.T) {
+	testCases := []struct {
+		name          string
+		volumes       []corev1.Volume
+		mounts        []corev1.VolumeMount
+		expectedError string
+	}{
+		{
+			name:          "volume clashing with an operator volume",
+			volumes:       []corev1.Volume{{Name: "trusted-ca"}},
+			expectedError: `extra volume "trusted-ca" clashes with an existing volume`,
+		},
+		{
+			name:          "duplicated extra volume",
+			volumes:       []corev1.Volume{{Name: "extra"}, {Name: "extra"}},
+			expectedError: `extra volume "extra" clashes with an existing volume`,
+		},
+		{
+			name:          "mount of an unknown volume",
+			mounts:        []corev1.VolumeMount{{Name: "missing", MountPath: "/etc/missing"}},
+			expectedError: `extra volume mount "missing" refers to an unknown volume`,
+		},
+		{
+			name:          "mount clashing with an operator mount",
+			volumes:       []corev1.Volume{{Name: "extra"}},
+			mounts:        []corev1.VolumeMount{{Name: "extra", MountPath: "/etc/pki/ca-trust/extracted/pem"}},
+			expectedError: `extra volume mount "extra" clashes with an existing mount at /etc/pki/ca-trust/extracted/pem`,
+		},
+		{
+			name:   "mount of an operator volume",
+			mounts: []corev1.VolumeMount{{Name: "cert", MountPath: "/etc/cert"}},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateExtraVolumes(&OperatorConfig{
+				TargetNamespace:   targetNamespace,
+				ExtraVolumes:      tc.volumes,
+				ExtraVolumeMounts: tc.mounts,
+			})
+			if tc.expectedError == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tc.expectedError {
+				t.Errorf("expected error %q, got: %v", tc.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestNewDeploymentInitContainers(t *testing.T) {
+	if initContainers := newDeployment(&OperatorConfig{TargetNamespace: targetNamespace}, nil).Spec.Template.Spec.InitContainers; len(initContainers) != 0 {
+		t.Errorf("expected no init containers by default, got: %v", initContainers)
+	}
+
+	config := &OperatorConfig{
+		TargetNamespace: targetNamespace,
+		ImagePullPolicy: corev1.PullIfNotPresent,
+		InitContainers: []corev1.Container{
+			{Name: "fetch-metadata", Image: "fetch-metadata-image"},
+			{Name: "pinned", Image: "pinned-image", ImagePullPolicy: corev1.PullAlways},
+		},
+	}
+	if err := validateInitContainers(config); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	initContainers := newDeployment(config, nil).Spec.Template.Spec.InitContainers
+	if len(initContainers) != 2 || initContainers[0].Name != "fetch-metadata" || initContainers[1].Name != "pinned" {
+		t.Fatalf("expected the init containers of the config, got: %v", initContainers)
+	}
+	if initContainers[0].ImagePullPolicy != corev1.PullIfNotPresent {
+		t.Errorf("expected the pull policy of the config to be set on fetch-metadata, got %q", initContainers[0].ImagePullPolicy)
+	}
+	if initContainers[1].ImagePullPolicy != corev1.PullAlways {
+		t.Errorf("expected the pull policy of pinned to be kept, got %q", initContainers[1].ImagePullPolicy)
+	}
+	if config.InitContainers[0].ImagePullPolicy != "" {
+		t.Errorf("expected the init containers of the config not to be modified")
+	}
+
+	// A changed list changes the hash the Deployment is updated on.
+	changed := *config
+	changed.InitContainers = config.InitContainers[:1]
+	previous, current := newDeployment(config, nil), newDeployment(&changed, nil)
+	setConfigHash(previous)
+	setConfigHash(current)
+	if previous.Annotations[configHashAnnotation] == current.Annotations[configHashAnnotation] {
+		t.Errorf("expected the config hash to change with the init containers")
+	}
+}
+
+func TestValidateInitContainers(t *testing.T) {
+	testCases := []struct {
+		name           string
+		initContainers []corev1.Container
+		expectedError  string
+	}{
+		{
+			name:           "distinct names",
+			initContainers: []corev1.Container{{Name: "first"}, {Name: "second"}},
+		},
+		{
+			name:           "name clashing with a controller container",
+			initContainers: []corev1.Container{{Name: "machine-controller"}},
+			expectedError:  `init container name "machine-controller" clashes with another container`,
+		},
+		{
+			name:           "duplicated name",
+			initContainers: []corev1.Container{{Name: "init"}, {Name: "init"}},
+			expectedError:  `init container name "init" clashes with another container`,
+		},
+		{
+			name:           "missing name",
+			initContainers: []corev1.Container{{Image: "init-image"}},
+			expectedError:  `init container with image "init-image" has no name`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateInitContainers(&OperatorConfig{
+				TargetNamespace: targetNamespace,
+				InitContainers:  tc.initContainers,
+			})
+			if tc.expectedError == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tc.expectedError {
+				t.Errorf("expected error %q, got: %v", tc.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestNewDeploymentTopologySpreadConstraints(t *testing.T) {
+	if constraints := newDeployment(&OperatorConfig{TargetNamespace: targetNamespace}, nil).Spec.Template.Spec.TopologySpreadConstraints; len(constraints) != 0 {
+		t.Errorf("expected no topology spread constraints by default, got: %v", constraints)
+	}
+
+	constraints := []corev1.TopologySpreadConstraint{{
+		MaxSkew:           1,
+		TopologyKey:       "topology.kubernetes.io/zone",
+		WhenUnsatisfiable: corev1.ScheduleAnyway,
+		LabelSelector: &metav1.LabelSelector{
+			MatchLabels: map[string]string{"k8s-app": "controller"},
+		},
+	}}
+	deployment := newDeployment(&OperatorConfig{TargetNamespace: targetNamespace, TopologySpreadConstraints: constraints}, nil)
+	if got := deployment.Spec.Template.Spec.TopologySpreadConstraints; !equality.Semantic.DeepEqual(got, constraints) {
+		t.Errorf("expected %v, got %v", constraints, got)
+	}
+}
+
+func TestNewDeploymentImagePullPolicy(t *testing.T) {
+	for _, policy := range []corev1.PullPolicy{"", corev1.PullAlways, corev1.PullIfNotPresent} {
+		t.Run(string(policy), func(t *testing.T) {
+			deployment := newDeployment(&OperatorConfig{TargetNamespace: targetNamespace, ImagePullPolicy: policy}, nil)
+			for _, c := range deployment.Spec.Template.Spec.Containers {
+				if c.ImagePullPolicy != policy {
+					t.Errorf("expected pull policy %q on container %s, got %q", policy, c.Name, c.ImagePullPolicy)
+				}
+			}
+		})
+	}
+}
+
+func TestNewDeploymentResources(t *testing.T) {
+	machineControllerResources := corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("100Mi")},
+		Limits:   corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("500Mi")},
+	}
+	proxyResources := corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("5m")},
+	}
+	config := &OperatorConfig{
+		TargetNamespace: targetNamespace,
+		Resources: map[string]corev1.ResourceRequirements{
+			"machine-controller": machineControllerResources,
+			"kube-rbac-proxy":    proxyResources,
+		},
+	}
+	if err := validateResources(config); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	defaults := newDeployment(&OperatorConfig{TargetNamespace: targetNamespace}, nil).Spec.Template.Spec.Containers
+	deployment := newDeployment(config, nil)
+	for i, c := range deployment.Spec.Template.Spec.Containers {
+		expected := defaults[i].Resources
+		switch {
+		case c.Name == "machine-controller":
+			expected = machineControllerResources
+		case strings.HasPrefix(c.Name, "kube-rbac-proxy-"):
+			expected = proxyResources
+		}
+		if !equality.Semantic.DeepEqual(c.Resources, expected) {
+			t.Errorf("expected resources %v on container %s, got %v", expected, c.Name, c.Resources)
+		}
+	}
+
+	config.Resources = map[string]corev1.ResourceRequirements{"unknown": {}}
+	if err := validateResources(config); err == nil {
+		t.Error("expected an error for resources of an unknown component")
+	}
+}
+
+func TestSyncControllersPodDisruptionBudget(t *testing.T) {
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	optr := newFakeOperator(nil, nil, stopCh)
+
+	getMinAvailable := func() int {
+		pdb, err := optr.kubeClient.PolicyV1beta1().PodDisruptionBudgets(targetNamespace).Get(context.Background(), "machine-api-controllers", metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return pdb.Spec.MinAvailable.IntValue()
+	}
+
+	config := &OperatorConfig{TargetNamespace: targetNamespace}
+	deployment := newDeployment(config, nil)
+	if err := optr.syncControllersPodDisruptionBudget(context.Background(), config, deployment); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := getMinAvailable(); got != 0 {
+		t.Errorf("expected minAvailable 0 for a single replica, got %d", got)
+	}
+
+	// The budget follows the replicas of the Deployment.
+	deployment.Spec.Replicas = pointer.Int32Ptr(3)
+	if err := optr.syncControllersPodDisruptionBudget(context.Background(), config, deployment); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := getMinAvailable(); got != 2 {
+		t.Errorf("expected minAvailable 2 for three replicas, got %d", got)
+	}
+
+	optr.options.ControllersMinAvailable = 1
+	if err := optr.syncControllersPodDisruptionBudget(context.Background(), config, deployment); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := getMinAvailable(); got != 1 {
+		t.Errorf("expected the configured minAvailable 1, got %d", got)
+	}
+}
+
+func TestSyncClusterAPIControllerApplyFailedEvent(t *testing.T) {
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	optr := newFakeOperator(nil, nil, stopCh)
+	optr.kubeClient.(*fakekube.Clientset).PrependReactor("create", "deployments", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		return true, nil, fmt.Errorf("create failed")
+	})
+
+	config := &OperatorConfig{
+		TargetNamespace: targetNamespace,
+		Controllers: Controllers{
+			Provider:           "provider-image",
+			TerminationHandler: clusterAPIControllerNoOp,
+		},
+	}
+	if err := optr.syncClusterAPIController(context.Background(), config); err == nil {
+		t.Fatal("expected an error applying the deployment")
+	}
+
+	recorder := optr.eventRecorder.(*record.FakeRecorder)
+	select {
+	case event := <-recorder.Events:
+		if !strings.HasPrefix(event, "Warning DeploymentUpdateFailed") {
+			t.Errorf("unexpected event: %s", event)
+		}
+	default:
+		t.Error("expected a warning event to be recorded")
+	}
+}
+
+func TestSyncValidatingWebhookManagedByLabel(t *testing.T) {
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	optr := newFakeOperator(nil, nil, stopCh)
+
+	// The operands must not be garbage collected along with the ClusterOperator,
+	// which admins may delete and recreate.
+	if _, err := optr.getOrCreateClusterOperator(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := optr.syncValidatingWebhook(context.Background(), &OperatorConfig{TargetNamespace: targetNamespace}); err != nil {
+		t.Fatal(err)
+	}
+	applied, err := optr.kubeClient.AdmissionregistrationV1().ValidatingWebhookConfigurations().Get(context.Background(), mapiv1.NewValidatingWebhookConfiguration().Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if applied.Labels[managedByLabel] != managedByLabelValue {
+		t.Errorf("expected %s label, got: %v", managedByLabel, applied.Labels)
+	}
+	if len(applied.OwnerReferences) != 0 {
+		t.Errorf("expected no owner references, got: %v", applied.OwnerReferences)
+	}
+}
+
+func TestSyncAllAppliesWebhooksFirst(t *testing.T) {
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	optr := newFakeOperator(nil, nil, stopCh)
+
+	var lock sync.Mutex
+	var created []string
+	optr.kubeClient.(*fakekube.Clientset).PrependReactor("create", "*", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		created = append(created, action.GetResource().Resource)
+		return false, nil, nil
+	})
+
+	config := &OperatorConfig{
+		TargetNamespace: targetNamespace,
+		Controllers: Controllers{
+			Provider:           "provider-image",
+			TerminationHandler: "termination-image",
+		},
+	}
+	// The rollouts never complete against the fake client, give up on them quickly.
+	optr.options.RolloutPollInterval = 10 * time.Millisecond
+	optr.options.RolloutTimeout = 50 * time.Millisecond
+	if err := optr.syncAll(context.Background(), config); err == nil {
+		t.Fatal("expected the rollouts to time out")
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	if len(created) != 5 {
+		t.Fatalf("expected 5 objects to be created, got: %v", created)
+	}
+	for i, resource := range created[:2] {
+		if !strings.HasSuffix(resource, "webhookconfigurations") {
+			t.Errorf("expected the webhook configurations to be created first, got %s at position %d: %v", resource, i, created)
+		}
+	}
+}
+
+func TestSyncAllContinuesAfterWebhookFailure(t *testing.T) {
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	optr := newFakeOperator(nil, nil, stopCh)
+	optr.kubeClient.(*fakekube.Clientset).PrependReactor("create", "*", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		if strings.HasSuffix(action.GetResource().Resource, "webhookconfigurations") {
+			return true, nil, fmt.Errorf("create %s failed", action.GetResource().Resource)
+		}
+		return false, nil, nil
+	})
+
+	config := &OperatorConfig{
+		TargetNamespace: targetNamespace,
+		Controllers: Controllers{
+			Provider:           "provider-image",
+			TerminationHandler: "termination-image",
+		},
+	}
+	// The rollouts never complete against the fake client, give up on them quickly.
+	optr.options.RolloutPollInterval = 10 * time.Millisecond
+	optr.options.RolloutTimeout = 50 * time.Millisecond
+	err := optr.syncAll(context.Background(), config)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, msg := range []string{"create validatingwebhookconfigurations failed", "create mutatingwebhookconfigurations failed"} {
+		if !strings.Contains(err.Error(), msg) {
+			t.Errorf("expected error to contain %q, got: %v", msg, err)
+		}
+	}
+
+	// The operands are still applied after the webhook configurations failed.
+	if _, err := optr.kubeClient.AppsV1().Deployments(targetNamespace).Get(context.Background(), "machine-api-controllers", metav1.GetOptions{}); err != nil {
+		t.Errorf("expected the deployment to be applied: %v", err)
+	}
+	if _, err := optr.kubeClient.AppsV1().DaemonSets(targetNamespace).Get(context.Background(), machineAPITerminationHandler, metav1.GetOptions{}); err != nil {
+		t.Errorf("expected the daemonset to be applied: %v", err)
+	}
+
+	co, err := optr.getClusterOperator(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	degraded := v1helpers.FindStatusCondition(co.Status.Conditions, configv1.OperatorDegraded)
+	if degraded == nil || !strings.Contains(degraded.Message, "create mutatingwebhookconfigurations failed") {
+		t.Errorf("expected the Degraded condition to list every failure, got: %v", degraded)
+	}
+}
+
+func TestSyncClusterAPIControllerAggregatesErrors(t *testing.T) {
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	optr := newFakeOperator(nil, nil, stopCh)
+	optr.kubeClient.(*fakekube.Clientset).PrependReactor("create", "*", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		return true, nil, fmt.Errorf("create %s failed", action.GetResource().Resource)
+	})
+
+	config := &OperatorConfig{
+		TargetNamespace: targetNamespace,
+		Controllers: Controllers{
+			Provider:           "provider-image",
+			TerminationHandler: "termination-image",
+		},
+	}
+	err := optr.syncClusterAPIController(context.Background(), config)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, msg := range []string{"create deployments failed", "create daemonsets failed"} {
+		if !strings.Contains(err.Error(), msg) {
+			t.Errorf("expected error to contain %q, got: %v", msg, err)
+		}
+	}
+}
+
+func TestPrintManifestsTargetNamespace(t *testing.T) {
+	config := &OperatorConfig{
+		TargetNamespace: "custom-namespace",
+		Controllers: Controllers{
+			Provider:           "provider-image",
+			TerminationHandler: "provider-image",
+		},
+	}
+
+	buf := &bytes.Buffer{}
+	if err := PrintManifests(config, DefaultControllersMinAvailable, buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, namespace := range []string{"openshift-machine-api", targetNamespace} {
+		if strings.Contains(buf.String(), namespace) {
+			t.Errorf("expected no object to reference the %q namespace, got:\n%s", namespace, buf.String())
+		}
+	}
+	if !strings.Contains(buf.String(), "namespace: custom-namespace") {
+		t.Errorf("expected objects to be rendered into the target namespace, got:\n%s", buf.String())
+	}
+}
+
+func TestWriteManifests(t *testing.T) {
+	dir, err := ioutil.TempDir("", "manifests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	config := &OperatorConfig{
+		TargetNamespace: targetNamespace,
+		Controllers: Controllers{
+			Provider:           "provider-image",
+			TerminationHandler: "provider-image",
+		},
+	}
+	if err := WriteManifests(config, DefaultControllersMinAvailable, dir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, path := range []string{
+		filepath.Join("Deployment", "machine-api-controllers.yaml"),
+		filepath.Join("PodDisruptionBudget", "machine-api-controllers.yaml"),
+		filepath.Join("DaemonSet", machineAPITerminationHandler+".yaml"),
+	} {
+		data, err := ioutil.ReadFile(filepath.Join(dir, path))
+		if err != nil {
+			t.Errorf("expected %s to be written: %v", path, err)
+			continue
+		}
+		if !strings.Contains(string(data), "namespace: "+targetNamespace) {
+			t.Errorf("expected %s to be rendered into the target namespace, got:\n%s", path, data)
+		}
+	}
+	for _, kind := range []string{"ValidatingWebhookConfiguration", "MutatingWebhookConfiguration"} {
+		files, err := ioutil.ReadDir(filepath.Join(dir, kind))
+		if err != nil || len(files) != 1 {
+			t.Errorf("expected one %s to be written, got: %v, %v", kind, files, err)
+		}
+	}
+
+	config.ExtraVolumeMounts = []corev1.VolumeMount{{Name: "missing", MountPath: "/missing"}}
+	if err := WriteManifests(config, DefaultControllersMinAvailable, dir); err == nil {
+		t.Error("expected an error for a mount of an unknown volume")
+	}
+}
+
+func TestSetConfigHash(t *testing.T) {
+	config := &OperatorConfig{TargetNamespace: targetNamespace}
+
+	first := newValidatingWebhookConfiguration(config)
+	if err := setConfigHash(first); err != nil {
+		t.Fatal(err)
+	}
+	hash := first.Annotations[configHashAnnotation]
+	if hash == "" {
+		t.Fatalf("expected the %s annotation to be set", configHashAnnotation)
+	}
+
+	// Hashing again must not take the previous hash into account.
+	if err := setConfigHash(first); err != nil {
+		t.Fatal(err)
+	}
+	if first.Annotations[configHashAnnotation] != hash {
+		t.Errorf("expected the hash to be stable, got %s and %s", hash, first.Annotations[configHashAnnotation])
+	}
+
+	changed := newValidatingWebhookConfiguration(&OperatorConfig{TargetNamespace: "other-namespace"})
+	if err := setConfigHash(changed); err != nil {
+		t.Fatal(err)
+	}
+	if changed.Annotations[configHashAnnotation] == hash {
+		t.Error("expected the hash to change along with the rendered object")
+	}
+}
+
+func TestSyncValidatingWebhookSkipsUnchanged(t *testing.T) {
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	optr := newFakeOperator(nil, nil, stopCh)
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	optr.validatingWebhookLister = admissionlisterv1.NewValidatingWebhookConfigurationLister(indexer)
+	config := &OperatorConfig{TargetNamespace: targetNamespace}
+
+	if err := optr.syncValidatingWebhook(context.Background(), config); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	applied, err := optr.kubeClient.AdmissionregistrationV1().ValidatingWebhookConfigurations().Get(context.Background(), "machine-api", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if applied.Annotations[configHashAnnotation] == "" {
+		t.Fatalf("expected the applied object to carry the %s annotation", configHashAnnotation)
+	}
+	if err := indexer.Add(applied); err != nil {
+		t.Fatal(err)
+	}
+
+	kubeClient := optr.kubeClient.(*fakekube.Clientset)
+	kubeClient.ClearActions()
+	if err := optr.syncValidatingWebhook(context.Background(), config); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if actions := kubeClient.Actions(); len(actions) != 0 {
+		t.Errorf("expected an unchanged webhook configuration not to be applied, got actions: %v", actions)
+	}
+
+	// Someone else changed the live object, so it is applied again.
+	changed := applied.DeepCopy()
+	changed.Generation++
+	if err := indexer.Update(changed); err != nil {
+		t.Fatal(err)
+	}
+	if err := optr.syncValidatingWebhook(context.Background(), config); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if actions := kubeClient.Actions(); len(actions) == 0 {
+		t.Error("expected a webhook configuration changed by someone else to be applied")
+	}
+}
+
+func TestNewControllersDeploymentCredentialsHash(t *testing.T) {
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "aws-cloud-credentials", Namespace: targetNamespace},
+		Data:       map[string][]byte{"aws_access_key_id": []byte("old")},
+	}
+	optr := newFakeOperator([]runtime.Object{secret}, nil, stopCh)
+	config := &OperatorConfig{TargetNamespace: targetNamespace, PlatformType: configv1.AWSPlatformType}
+
+	annotation := "operator.openshift.io/dep-" + targetNamespace + ".aws-cloud-credentials.secret"
+	getHash := func() string {
+		deployment, err := optr.newControllersDeployment(config)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return deployment.Spec.Template.Annotations[annotation]
+	}
+
+	oldHash := getHash()
+	if oldHash == "" {
+		t.Fatalf("expected the pod template to carry the %s annotation", annotation)
+	}
+
+	secret.Data["aws_access_key_id"] = []byte("new")
+	if _, err := optr.kubeClient.CoreV1().Secrets(targetNamespace).Update(context.Background(), secret, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if newHash := getHash(); newHash == oldHash {
+		t.Error("expected the rotated credentials to change the pod template")
+	}
+}
+
+func TestSyncConcurrentlyBoundsParallelism(t *testing.T) {
+	var lock sync.Mutex
+	running, maxRunning := 0, 0
+	syncs := make([]func(context.Context, *OperatorConfig) error, 3*maxConcurrentSyncs)
+	for i := range syncs {
+		syncs[i] = func(context.Context, *OperatorConfig) error {
+			lock.Lock()
+			running++
+			if running > maxRunning {
+				maxRunning = running
+			}
+			lock.Unlock()
+
+			time.Sleep(10 * time.Millisecond)
+
+			lock.Lock()
+			running--
+			lock.Unlock()
+			return nil
+		}
+	}
+	syncs[0] = func(context.Context, *OperatorConfig) error { return fmt.Errorf("first failed") }
+	syncs[len(syncs)-1] = func(context.Context, *OperatorConfig) error { return fmt.Errorf("last failed") }
+
+	err := syncConcurrently(context.Background(), &OperatorConfig{}, syncs...)
+	if err == nil || !strings.Contains(err.Error(), "first failed") || !strings.Contains(err.Error(), "last failed") {
+		t.Errorf("expected the errors of all syncs to be aggregated, got: %v", err)
+	}
+	if maxRunning > maxConcurrentSyncs {
+		t.Errorf("expected at most %d syncs to run at once, got %d", maxConcurrentSyncs, maxRunning)
+	}
+	if maxRunning < 2 {
+		t.Errorf("expected syncs to run concurrently, got %d at most", maxRunning)
+	}
+}
+
+// BenchmarkSyncConcurrently compares applying a set of objects one after the
+// other to applying them concurrently, with each apply taking about as long as
+// a round trip to the API server.
+func BenchmarkSyncConcurrently(b *testing.B) {
+	apply := func(context.Context, *OperatorConfig) error {
+		time.Sleep(5 * time.Millisecond)
+		return nil
+	}
+	// About as many objects as a full sync applies.
+	syncs := make([]func(context.Context, *OperatorConfig) error, 6)
+	for i := range syncs {
+		syncs[i] = apply
+	}
+	config := &OperatorConfig{}
+
+	b.Run("serial", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			for _, s := range syncs {
+				if err := s(context.Background(), config); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+	b.Run("concurrent", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			if err := syncConcurrently(context.Background(), config, syncs...); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestCheckNamespaceAllowed(t *testing.T) {
+	inNamespace := func(namespace string) metav1.Object {
+		return &metav1.ObjectMeta{Name: "machine-api-controllers", Namespace: namespace}
+	}
+	testCases := []struct {
+		name              string
+		allowedNamespaces []string
+		obj               metav1.Object
+		expectedErr       bool
+	}{
+		{
+			name: "target namespace allowed by default",
+			obj:  inNamespace(targetNamespace),
+		},
+		{
+			name:        "other namespace rejected by default",
+			obj:         inNamespace("kube-system"),
+			expectedErr: true,
+		},
+		{
+			name:              "allowed namespace",
+			allowedNamespaces: []string{"kube-system"},
+			obj:               inNamespace("kube-system"),
+		},
+		{
+			name:              "target namespace not in the allowed namespaces",
+			allowedNamespaces: []string{"kube-system"},
+			obj:               inNamespace(targetNamespace),
+			expectedErr:       true,
+		},
+		{
+			name: "cluster-scoped object",
+			obj:  inNamespace(""),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := &OperatorConfig{TargetNamespace: targetNamespace, AllowedNamespaces: tc.allowedNamespaces}
+			err := checkNamespaceAllowed(config, tc.obj)
+			if tc.expectedErr != (err != nil) {
+				t.Fatalf("expected error: %v, got: %v", tc.expectedErr, err)
+			}
+			if err != nil && (!errors.Is(err, ErrNamespaceNotAllowed) || !isPermanentError(err)) {
+				t.Errorf("expected a permanent ErrNamespaceNotAllowed, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestSyncControllersDeploymentNamespaceNotAllowed(t *testing.T) {
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	optr := newFakeOperator(nil, nil, stopCh)
+	config := &OperatorConfig{
+		TargetNamespace:   targetNamespace,
+		AllowedNamespaces: []string{"other-namespace"},
+		Controllers:       Controllers{Provider: "provider-image"},
+	}
+
+	err := optr.syncControllersDeployment(context.Background(), config)
+	if !errors.Is(err, ErrNamespaceNotAllowed) {
+		t.Fatalf("expected ErrNamespaceNotAllowed, got: %v", err)
+	}
+	for _, action := range optr.kubeClient.(*fakekube.Clientset).Actions() {
+		if action.GetVerb() == "create" || action.GetVerb() == "update" {
+			t.Errorf("expected nothing to be written, got: %v", action)
+		}
+	}
+}
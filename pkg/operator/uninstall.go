@@ -0,0 +1,75 @@
+package operator
+
+import (
+	"context"
+	"fmt"
+
+	mapiv1 "github.com/openshift/machine-api-operator/pkg/apis/machine/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+)
+
+// DeleteOperands deletes every object the operator applies, in the reverse of
+// the order they are synced in. Objects which are already gone are skipped so
+// it is safe to run it again after a partial failure.
+// The operator must be stopped first, or it will recreate the objects.
+func DeleteOperands(ctx context.Context, kubeClient kubernetes.Interface, namespace string) error {
+	propagation := metav1.DeletePropagationForeground
+	opts := metav1.DeleteOptions{PropagationPolicy: &propagation}
+
+	deletes := []struct {
+		kind, name string
+		delete     func(name string) error
+	}{
+		{
+			kind: "DaemonSet",
+			name: machineAPITerminationHandler,
+			delete: func(name string) error {
+				return kubeClient.AppsV1().DaemonSets(namespace).Delete(ctx, name, opts)
+			},
+		},
+		{
+			kind: "PodDisruptionBudget",
+			name: "machine-api-controllers",
+			delete: func(name string) error {
+				return kubeClient.PolicyV1beta1().PodDisruptionBudgets(namespace).Delete(ctx, name, opts)
+			},
+		},
+		{
+			kind: "Deployment",
+			name: "machine-api-controllers",
+			delete: func(name string) error {
+				return kubeClient.AppsV1().Deployments(namespace).Delete(ctx, name, opts)
+			},
+		},
+		{
+			kind: "MutatingWebhookConfiguration",
+			name: mapiv1.NewMutatingWebhookConfiguration().Name,
+			delete: func(name string) error {
+				return kubeClient.AdmissionregistrationV1().MutatingWebhookConfigurations().Delete(ctx, name, opts)
+			},
+		},
+		{
+			kind: "ValidatingWebhookConfiguration",
+			name: mapiv1.NewValidatingWebhookConfiguration().Name,
+			delete: func(name string) error {
+				return kubeClient.AdmissionregistrationV1().ValidatingWebhookConfigurations().Delete(ctx, name, opts)
+			},
+		},
+	}
+
+	for _, d := range deletes {
+		err := d.delete(d.name)
+		if apierrors.IsNotFound(err) {
+			klog.Infof("%s %s is already gone", d.kind, d.name)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to delete %s %s: %w", d.kind, d.name, err)
+		}
+		klog.Infof("Deleted %s %s", d.kind, d.name)
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package operator
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	mapiv1 "github.com/openshift/machine-api-operator/pkg/apis/machine/v1beta1"
+	appsv1 "k8s.io/api/apps/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakekube "k8s.io/client-go/kubernetes/fake"
+)
+
+func TestDeleteOperands(t *testing.T) {
+	g := NewWithT(t)
+
+	kubeClient := fakekube.NewSimpleClientset(
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "machine-api-controllers", Namespace: targetNamespace}},
+		&policyv1beta1.PodDisruptionBudget{ObjectMeta: metav1.ObjectMeta{Name: "machine-api-controllers", Namespace: targetNamespace}},
+		&appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Name: machineAPITerminationHandler, Namespace: targetNamespace}},
+		mapiv1.NewValidatingWebhookConfiguration(),
+		mapiv1.NewMutatingWebhookConfiguration(),
+	)
+
+	g.Expect(DeleteOperands(context.Background(), kubeClient, targetNamespace)).To(Succeed())
+
+	deployments, err := kubeClient.AppsV1().Deployments(targetNamespace).List(context.Background(), metav1.ListOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(deployments.Items).To(BeEmpty())
+	pdbs, err := kubeClient.PolicyV1beta1().PodDisruptionBudgets(targetNamespace).List(context.Background(), metav1.ListOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(pdbs.Items).To(BeEmpty())
+	daemonSets, err := kubeClient.AppsV1().DaemonSets(targetNamespace).List(context.Background(), metav1.ListOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(daemonSets.Items).To(BeEmpty())
+	validating, err := kubeClient.AdmissionregistrationV1().ValidatingWebhookConfigurations().List(context.Background(), metav1.ListOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(validating.Items).To(BeEmpty())
+	mutating, err := kubeClient.AdmissionregistrationV1().MutatingWebhookConfigurations().List(context.Background(), metav1.ListOptions{})
+	g.Expect(err).ToNot(HaveOccurred())
+	g.Expect(mutating.Items).To(BeEmpty())
+
+	// Everything is already gone, running it again is a no-op.
+	g.Expect(DeleteOperands(context.Background(), kubeClient, targetNamespace)).To(Succeed())
+}
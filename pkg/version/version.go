@@ -17,4 +17,28 @@ var (
 
 	// String is the human-friendly representation of the version.
 	String = fmt.Sprintf("MachineAPIOperator %s", Raw)
+
+	// Commit is the git commit the binary was built from. This will be replaced
+	// at build time.
+	Commit = "unknown"
+
+	// BuildDate is the time the binary was built at. This will be replaced at
+	// build time.
+	BuildDate = "unknown"
 )
+
+// Info holds the build metadata of the binary.
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"buildDate"`
+}
+
+// Get returns the build metadata of the binary.
+func Get() Info {
+	return Info{
+		Version:   Raw,
+		Commit:    Commit,
+		BuildDate: BuildDate,
+	}
+}